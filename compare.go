@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// setCorpus sets the corpus of the file, which is the index of the path
+// it was found in, in compare and submissions mode.
+func setCorpus(cfg *golang.Config, filename string, corpus int) {
+	if *compare || *submissions {
+		cfg.SetCorpus(filename, corpus)
+	}
+}
+
+// spansCorpora reports whether the fragments come from at least two
+// corpora, that is, from each of the compared corpora. The reference
+// index is not a compared corpus.
+func spansCorpora(frags [][]*syntax.Node) bool {
+	first := refCorpus
+	for _, frag := range frags {
+		switch corpus := frag[0].Corpus; {
+		case corpus == refCorpus:
+		case first == refCorpus:
+			first = corpus
+		case corpus != first:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+func TestSpansCorpora(t *testing.T) {
+	testCases := []struct {
+		corpora []int
+		spans   bool
+	}{
+		{[]int{0, 0}, false},
+		{[]int{0, 1}, true},
+		{[]int{1, 1, 1}, false},
+		{[]int{1, 1, 0}, true},
+		{[]int{0, refCorpus}, false},
+		{[]int{refCorpus, 0, 0}, false},
+		{[]int{refCorpus, 0, 1}, true},
+	}
+	for _, tc := range testCases {
+		var frags [][]*syntax.Node
+		for _, corpus := range tc.corpora {
+			frags = append(frags, []*syntax.Node{{Corpus: corpus}})
+		}
+		if spans := spansCorpora(frags); spans != tc.spans {
+			t.Errorf("%v: got %v, want %v", tc.corpora, spans, tc.spans)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// minDeclMembers is the minimum number of fields or methods of type
+// declarations reported by -decls.
+const minDeclMembers = 2
+
+type typeDecl struct {
+	golang.TypeDecl
+	filename string
+	pkg      string
+}
+
+// kind returns the kind of the type.
+func (d *typeDecl) kind() string {
+	if d.Interface {
+		return "interface"
+	}
+	return "struct"
+}
+
+// findDecls returns the groups of struct types with at least -decl-similarity
+// percent of the same fields and interfaces with the same methods declared
+// in several packages ranked by their numbers of members. Each pair of the
+// declarations in a group is similar.
+func findDecls(data []*syntax.Node, cfg *golang.Config) ([]syntax.Match, error) {
+	var decls []typeDecl
+	seen := make(map[string]bool)
+	for _, n := range data {
+		// the files in the reference index are not searched
+		if n.Filename == "" || n.Corpus == refCorpus || seen[n.Filename] {
+			continue
+		}
+		seen[n.Filename] = true
+		file, err := readFile(n.Filename)
+		if err != nil {
+			return nil, err
+		}
+		fdecls, err := golang.TypeDecls(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range fdecls {
+			if len(d.Members) >= minDeclMembers {
+				// the external test package is different from the package
+				pkg := filepath.Dir(n.Filename) + " " + cfg.Package(n.Filename)
+				decls = append(decls, typeDecl{d, n.Filename, pkg})
+			}
+		}
+	}
+
+	// group the declarations sharing some members, each declaration joins
+	// the first group of declarations all similar to it
+	byMember := make(map[string][]int) // by the kinds of types and members
+	for i, d := range decls {
+		for _, m := range d.Members {
+			byMember[d.kind()+" "+m] = append(byMember[d.kind()+" "+m], i)
+		}
+	}
+	var groups [][]int
+	groupOf := make([]int, len(decls))
+	for i := range decls {
+		d := &decls[i]
+		seen := make(map[int]bool)
+		var candidates []int
+		for _, m := range d.Members {
+			for _, j := range byMember[d.kind()+" "+m] {
+				if j < i && !seen[groupOf[j]] {
+					seen[groupOf[j]] = true
+					candidates = append(candidates, groupOf[j])
+				}
+			}
+		}
+		sort.Ints(candidates)
+		g := -1
+		for _, c := range candidates {
+			if similarToAll(d, decls, groups[c]) {
+				g = c
+				break
+			}
+		}
+		if g < 0 {
+			g = len(groups)
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+		groupOf[i] = g
+	}
+
+	var dupls []syntax.Match
+	for _, group := range groups {
+		pkgs := make(map[string]bool)
+		for _, i := range group {
+			pkgs[decls[i].pkg] = true
+		}
+		if len(pkgs) < 2 {
+			continue
+		}
+		first := &decls[group[0]]
+		m := syntax.Match{
+			Hash:       fmt.Sprintf("decl %s %s", first.filename, first.Name),
+			Similarity: 1,
+			Decl:       first.kind() + " " + first.Name,
+			Size:       float64(len(first.Members)),
+		}
+		for k, i := range group {
+			d := &decls[i]
+			m.Frags = append(m.Frags, []*syntax.Node{{Filename: d.filename, Pos: d.Pos, End: d.End}})
+			for _, j := range group[:k] {
+				if sim := membersSimilarity(d, &decls[j]); sim < m.Similarity {
+					m.Similarity = sim
+				}
+			}
+		}
+		dupls = append(dupls, m)
+	}
+	sort.SliceStable(dupls, func(i, j int) bool { return dupls[i].Size > dupls[j].Size })
+	return dupls, nil
+}
+
+// similarToAll reports whether the declaration d is similar to each
+// of the declarations in the group.
+func similarToAll(d *typeDecl, decls []typeDecl, group []int) bool {
+	for _, i := range group {
+		if !similarDecls(d, &decls[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// similarDecls reports whether the declarations are similar enough:
+// interfaces must have the same methods while structs must share at
+// least -decl-similarity percent of the fields.
+func similarDecls(d1, d2 *typeDecl) bool {
+	if d1.Interface != d2.Interface {
+		return false
+	}
+	sim := membersSimilarity(d1, d2)
+	if d1.Interface {
+		return sim == 1
+	}
+	return sim*100 >= float64(*declSimilarity)
+}
+
+// membersSimilarity returns the ratio of the members shared by the
+// declarations to all their members.
+func membersSimilarity(d1, d2 *typeDecl) float64 {
+	var shared int
+	for i, j := 0, 0; i < len(d1.Members) && j < len(d2.Members); {
+		switch {
+		case d1.Members[i] == d2.Members[j]:
+			shared++
+			i++
+			j++
+		case d1.Members[i] < d2.Members[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return float64(shared) / float64(len(d1.Members)+len(d2.Members)-shared)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+func TestFindDecls(t *testing.T) {
+	// B is similar to both A and C, but A and C are not similar
+	files := writeFiles(t, map[string]string{
+		"a/a.go": "package a\n\ntype A struct {\n\ta, b, c int\n}\n",
+		"b/b.go": "package b\n\ntype B struct {\n\ta, b, d int\n}\n",
+		"c/c.go": "package c\n\ntype C struct {\n\ta, d, e int\n}\n",
+	})
+	var data []*syntax.Node
+	for _, name := range []string{"a/a.go", "b/b.go", "c/c.go"} {
+		data = append(data, &syntax.Node{Filename: files[name]})
+	}
+
+	defer func(old int) { *declSimilarity = old }(*declSimilarity)
+	*declSimilarity = 50
+	dupls, err := findDecls(data, new(golang.Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dupls) != 1 {
+		t.Fatalf("got %d groups, want 1", len(dupls))
+	}
+	if m := dupls[0]; len(m.Frags) != 2 || m.Similarity < 0.5 {
+		t.Errorf("got %d declarations %v similar, want 2 at least 50%% similar", len(m.Frags), m.Similarity)
+	}
+}
@@ -0,0 +1,178 @@
+// Package diff computes unified diffs of files.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// context is the number of unchanged lines around the changes in a hunk.
+const context = 3
+
+// Unified returns the unified diff of the files a and b named aName and
+// bName, or nil if they are the same.
+func Unified(aName, bName string, a, b []byte) []byte {
+	script := diffLines(splitLines(a), splitLines(b))
+	// aLines[i] and bLines[i] are the numbers of the lines of a and b
+	// before the i-th line of the script
+	aLines := make([]int, len(script)+1)
+	bLines := make([]int, len(script)+1)
+	for i, l := range script {
+		aLines[i+1], bLines[i+1] = aLines[i], bLines[i]
+		if l.op != '+' {
+			aLines[i+1]++
+		}
+		if l.op != '-' {
+			bLines[i+1]++
+		}
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < len(script); {
+		if script[i].op == ' ' {
+			i++
+			continue
+		}
+		if buf.Len() == 0 {
+			fmt.Fprintf(&buf, "--- %s\n+++ %s\n", aName, bName)
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		// extend the hunk over the changes separated by few unchanged lines
+		end := i
+		for end < len(script) {
+			if script[end].op != ' ' {
+				end++
+				continue
+			}
+			j := end
+			for j < len(script) && script[j].op == ' ' {
+				j++
+			}
+			if j == len(script) || j-end > 2*context {
+				end += context
+				if end > len(script) {
+					end = len(script)
+				}
+				break
+			}
+			end = j
+		}
+
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(aLines[start], aLines[end]),
+			hunkRange(bLines[start], bLines[end]))
+		for _, l := range script[start:end] {
+			buf.WriteByte(l.op)
+			buf.WriteString(l.text)
+			if l.text[len(l.text)-1] != '\n' {
+				buf.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// hunkRange returns the range of the lines from+1 to to of a hunk.
+func hunkRange(from, to int) string {
+	if to-from == 0 {
+		return fmt.Sprintf("%d,0", from)
+	}
+	if to-from == 1 {
+		return fmt.Sprint(to)
+	}
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+// splitLines splits the file into lines including their newlines.
+func splitLines(file []byte) []string {
+	var lines []string
+	for len(file) > 0 {
+		i := bytes.IndexByte(file, '\n') + 1
+		if i == 0 {
+			i = len(file)
+		}
+		lines = append(lines, string(file[:i]))
+		file = file[i:]
+	}
+	return lines
+}
+
+// A line is a line of an edit script: unchanged (' '), deleted ('-')
+// or inserted ('+').
+type line struct {
+	op   byte
+	text string
+}
+
+// diffLines returns the shortest edit script turning a into b computed
+// by the Myers algorithm.
+func diffLines(a, b []string) []line {
+	n, m := len(a), len(b)
+	max := n + m
+	// v[max+k] is the furthest x reached on the diagonal k = x-y
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	var d int
+search:
+	for ; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[max+k-1] < v[max+k+1] {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// backtrack the edits
+	var script []line
+	x, y := n, m
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || k != d && v[max+k-1] < v[max+k+1] {
+			prevK = k + 1
+		}
+		prevX := v[max+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			script = append(script, line{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			script = append(script, line{'+', b[y-1]})
+			y--
+		} else {
+			script = append(script, line{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		script = append(script, line{' ', a[x-1]})
+		x--
+		y--
+	}
+	for i, j := 0, len(script)-1; i < j; i, j = i+1, j-1 {
+		script[i], script[j] = script[j], script[i]
+	}
+	return script
+}
@@ -0,0 +1,26 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	testCases := []struct {
+		a, b   string
+		expect string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{"a\nb\nc\n", "a\nx\nc\n", "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{"", "a\n", "--- a\n+++ b\n@@ -0,0 +1 @@\n+a\n"},
+		{"a\nb", "a\nc\n", "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n"},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n12\n",
+			"--- a\n+++ b\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -8,5 +9,4 @@\n 8\n 9\n 10\n-11\n 12\n",
+		},
+	}
+	for _, tc := range testCases {
+		actual := string(Unified("a", "b", []byte(tc.a), []byte(tc.b)))
+		if actual != tc.expect {
+			t.Errorf("for %q and %q, got\n%s\nwant\n%s", tc.a, tc.b, actual, tc.expect)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// fileCache caches the contents of files read while filtering a group.
+type fileCache map[string][]byte
+
+func (c fileCache) read(filename string) ([]byte, error) {
+	if file, ok := c[filename]; ok {
+		return file, nil
+	}
+	file, err := readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c[filename] = file
+	return file, nil
+}
+
+// filterDupls drops the clones that do not satisfy the configured limits
+// and the groups out of the configured scope, not spanning several corpora
+// in compare and submissions mode, not matching the reference index or,
+// with -tests, not replaceable by table-driven tests.
+// With -min-similarity, it drops the groups that are not textually
+// similar enough. The textual similarities are kept with -text-similarity.
+func filterDupls(dupls []syntax.Match, cfg *golang.Config) ([]syntax.Match, error) {
+	var filtered []syntax.Match
+	mods := make(moduleCache)
+	for _, dupl := range dupls {
+		files := make(fileCache)
+		var frags [][]*syntax.Node
+		for _, frag := range dupl.Frags {
+			file, err := files.read(frag[0].Filename)
+			if err != nil {
+				return nil, err
+			}
+			if satisfiesLimits(frag, file) {
+				frags = append(frags, frag)
+			}
+		}
+		if len(frags) < 2 || *scope != "" && !satisfiesScope(frags, cfg, mods) ||
+			(*compare || *submissions) && !spansCorpora(frags) ||
+			*ref != "" && !matchesRef(frags) {
+			continue
+		}
+		dupl.Frags = frags
+
+		if *textSimilarity || *minSimilarity > 0 {
+			if err := setTextSimilarity(&dupl, files); err != nil {
+				return nil, err
+			}
+			if dupl.TextSimilarity*100 < float64(*minSimilarity) {
+				continue
+			}
+			if !*textSimilarity {
+				dupl.TextSimilarity, dupl.PairSimilarity = 0, nil
+			}
+		}
+		if *tests {
+			table, ok := cfg.SuggestTable(frags)
+			if !ok {
+				continue
+			}
+			dupl.Suggestions = append(dupl.Suggestions, "replace with table-driven test:\n"+table)
+		}
+		if *renames {
+			setWarnings(&dupl, files)
+		}
+		if *suggest {
+			setSuggestions(&dupl, files, cfg)
+		}
+		filtered = append(filtered, dupl)
+	}
+	return filtered, nil
+}
+
+// satisfiesLimits reports whether the fragment has at least the minimum
+// number of lines, statements and distinct node kinds.
+func satisfiesLimits(frag []*syntax.Node, file []byte) bool {
+	if *minLines > 0 {
+		if lines := bytes.Count(fragSrc(frag, file), []byte("\n")) + 1; lines < *minLines {
+			return false
+		}
+	}
+	if *minStmts == 0 && *minKinds == 0 {
+		return true
+	}
+
+	var stmts int
+	kinds := make(map[int]bool)
+	var walk func(n *syntax.Node)
+	walk = func(n *syntax.Node) {
+		if golang.IsStmt(n) {
+			stmts++
+		}
+		kinds[golang.Kind(n.Type)] = true
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	for _, n := range frag {
+		walk(n)
+	}
+	return stmts >= *minStmts && len(kinds) >= *minKinds
+}
+
+// fragSrc returns the source of the fragment in the file or nil if the
+// fragment is not within the file.
+func fragSrc(frag []*syntax.Node, file []byte) []byte {
+	pos, end := syntax.Span(frag)
+	if pos < 0 || end > len(file) {
+		return nil
+	}
+	return file[pos:end]
+}
+
+func setTextSimilarity(m *syntax.Match, files fileCache) error {
+	toks := make([][]string, len(m.Frags))
+	for i, frag := range m.Frags {
+		file, err := files.read(frag[0].Filename)
+		if err != nil {
+			return err
+		}
+		toks[i] = golang.Tokens(fragSrc(frag, file))
+	}
+
+	var sum float64
+	m.PairSimilarity = make([][]float64, len(toks))
+	for i := range toks {
+		m.PairSimilarity[i] = make([]float64, len(toks))
+		m.PairSimilarity[i][i] = 1
+		for j := 0; j < i; j++ {
+			sim := golang.TextSimilarity(toks[i], toks[j])
+			m.PairSimilarity[i][j], m.PairSimilarity[j][i] = sim, sim
+			sum += sim
+		}
+	}
+	m.TextSimilarity = sum / float64(len(toks)*(len(toks)-1)/2)
+	return nil
+}
+
+// setSuggestions suggests refactorings of the clones.
+func setSuggestions(m *syntax.Match, files fileCache, cfg *golang.Config) {
+	if sig, ok := golang.SuggestGeneric(m.Frags, files.read); ok {
+		m.Suggestions = append(m.Suggestions, "replace with generic "+sig)
+	}
+	if table, ok := cfg.SuggestTable(m.Frags); ok && !*tests {
+		m.Suggestions = append(m.Suggestions, "replace with table-driven test:\n"+table)
+	}
+	if ext, ok := cfg.SuggestExtract(m.Frags); ok {
+		m.Suggestions = append(m.Suggestions, "extract "+ext.Func)
+		for i, call := range ext.Calls {
+			frag := m.Frags[i]
+			file, _ := files.read(frag[0].Filename)
+			pos, end := syntax.Span(frag)
+			m.Suggestions = append(m.Suggestions, fmt.Sprintf("replace %s:%d,%d with %s", frag[0].Filename,
+				lineOf(file, pos), lineOf(file, end-1), call))
+		}
+	}
+}
+
+// setWarnings warns about the identifiers of the clones that are not
+// renamed although their other occurrences are.
+func setWarnings(m *syntax.Match, files fileCache) {
+	seen := make(map[string]bool)
+	for i, a := range m.Frags {
+		for j, b := range m.Frags {
+			if i == j {
+				continue
+			}
+			fa, _ := files.read(a[0].Filename)
+			fb, _ := files.read(b[0].Filename)
+			srcA, srcB := fragSrc(a, fa), fragSrc(b, fb)
+			if srcA == nil || srcB == nil {
+				continue
+			}
+			posA, _ := syntax.Span(a)
+			posB, _ := syntax.Span(b)
+			for _, u := range golang.FindUnrenamed(srcA, srcB) {
+				offset := posB + u.Offset
+				line := lineOf(fb, offset)
+				col := offset - bytes.LastIndexByte(fb[:offset], '\n')
+				loc := fmt.Sprintf("%s:%d:%d", b[0].Filename, line, col)
+				if seen[loc] {
+					continue
+				}
+				seen[loc] = true
+				m.Warnings = append(m.Warnings, fmt.Sprintf("%s: %s is not renamed to %s as in %s:%d",
+					loc, u.Name, u.Expected, a[0].Filename, lineOf(fa, posA)))
+			}
+		}
+	}
+}
+
+// lineOf returns the line of the offset in the file.
+func lineOf(file []byte, offset int) int {
+	return bytes.Count(file[:offset], []byte("\n")) + 1
+}
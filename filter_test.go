@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/mibk/dupl/job"
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// writeFiles writes the files with the sources in a temporary directory
+// and returns their names.
+func writeFiles(t *testing.T, srcs map[string]string) map[string]string {
+	dir := t.TempDir()
+	names := make(map[string]string)
+	for name, src := range srcs {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(src), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		names[name] = filename
+	}
+	return names
+}
+
+func TestFilterDuplsTextSimilarity(t *testing.T) {
+	files := writeFiles(t, map[string]string{
+		"a.go": "package p\n\nvar a = x + y\n",
+		"b.go": "package p\n\nvar b = x - z\n",
+	})
+	frag := func(name string) []*syntax.Node {
+		return []*syntax.Node{{Filename: files[name], Pos: 11, End: 24}}
+	}
+	defer func(min int, text bool) { *minSimilarity, *textSimilarity = min, text }(*minSimilarity, *textSimilarity)
+	testCases := []struct {
+		minSimilarity  int
+		textSimilarity bool
+		reported       bool
+		similarity     bool
+	}{
+		{0, false, true, false},
+		{0, true, true, true},
+		{50, false, true, false},
+		{50, true, true, true},
+		{90, true, false, false},
+	}
+	for _, tc := range testCases {
+		*minSimilarity, *textSimilarity = tc.minSimilarity, tc.textSimilarity
+		dupls := []syntax.Match{{Frags: [][]*syntax.Node{frag("a.go"), frag("b.go")}}}
+		filtered, err := filterDupls(dupls, new(golang.Config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(filtered) == 1 != tc.reported {
+			t.Errorf("-min-similarity %d -text-similarity=%v: got %d groups, want reported %v",
+				tc.minSimilarity, tc.textSimilarity, len(filtered), tc.reported)
+			continue
+		}
+		if tc.reported && (filtered[0].PairSimilarity != nil) != tc.similarity {
+			t.Errorf("-min-similarity %d -text-similarity=%v: got pair similarities %v",
+				tc.minSimilarity, tc.textSimilarity, filtered[0].PairSimilarity)
+		}
+	}
+}
+
+const limitsSrc = `package p
+
+func f(a int) int {
+	a++
+	if a > 0 {
+		a--
+	}
+	return a
+}
+`
+
+func TestSatisfiesLimits(t *testing.T) {
+	files := writeFiles(t, map[string]string{"p.go": limitsSrc})
+	root, err := golang.Parse(files["p.go"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the fragment is the body of f: 5 lines, 4 statements and 7 kinds
+	// of nodes (IncDecStmt, Ident, IfStmt, BinaryExpr, BasicLit,
+	// BlockStmt and ReturnStmt)
+	fn := root.Children[0]
+	body := fn.Children[len(fn.Children)-1]
+	frag := body.Children
+	file := []byte(limitsSrc)
+
+	defer func(lines, stmts, kinds int) {
+		*minLines, *minStmts, *minKinds = lines, stmts, kinds
+	}(*minLines, *minStmts, *minKinds)
+	testCases := []struct {
+		lines, stmts, kinds int
+		ok                  bool
+	}{
+		{0, 0, 0, true},
+		{5, 4, 7, true},
+		{6, 0, 0, false},
+		{0, 5, 0, false},
+		{0, 0, 8, false},
+		{6, 4, 7, false},
+		{5, 5, 7, false},
+		{5, 4, 8, false},
+	}
+	for _, tc := range testCases {
+		*minLines, *minStmts, *minKinds = tc.lines, tc.stmts, tc.kinds
+		if ok := satisfiesLimits(frag, file); ok != tc.ok {
+			t.Errorf("-min-lines %d -min-stmts %d -min-kinds %d: got %v, want %v",
+				tc.lines, tc.stmts, tc.kinds, ok, tc.ok)
+		}
+	}
+}
+
+// The right-hand sides of assignments are serialized before the left-hand
+// sides, so the clone of the arguments of the call starts after it ends
+// in the assignment.
+const assignSrc = `package p
+
+func f() {
+	h(x.y, a[b])
+}
+
+func g() {
+	a[b] = x.y
+}
+`
+
+func TestFilterDuplsAssignments(t *testing.T) {
+	files := writeFiles(t, map[string]string{"p.go": assignSrc})
+	root, err := golang.Parse(files["p.go"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	schan := make(chan []*syntax.Node, 1)
+	schan <- syntax.Serialize(root)
+	close(schan)
+	tree, data, done := job.BuildTree(schan, false)
+	<-done
+	tree.Update(&syntax.Node{Type: -1})
+
+	defer func(t, lines, sim int, ren bool) {
+		*threshold, *minLines, *minSimilarity, *renames = t, lines, sim, ren
+	}(*threshold, *minLines, *minSimilarity, *renames)
+	*threshold, *minLines, *minSimilarity, *renames = 2, 1, 0, true
+	filtered, err := filterDupls(groupDupls(findDupls(tree, *data, nil)), new(golang.Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, m := range filtered {
+		var srcs []string
+		for _, frag := range m.Frags {
+			srcs = append(srcs, string(fragSrc(frag, []byte(assignSrc))))
+		}
+		sort.Strings(srcs)
+		if len(srcs) == 2 && srcs[0] == "a[b] = x.y" && srcs[1] == "x.y, a[b]" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("the clone of the assignment not found")
+	}
+}
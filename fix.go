@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/mibk/dupl/diff"
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// fixDupls rewrites the clone groups into calls of functions extracted
+// from them and prints the diffs of the rewritten files or, with -w,
+// writes them.
+func fixDupls(dupls []syntax.Match, cfg *golang.Config) error {
+	fixer := cfg.NewFixer()
+	var fixed int
+	for _, dupl := range dupls {
+		if fixer.Fix(dupl.Frags) {
+			fixed++
+		}
+	}
+	if *verbose {
+		log.Printf("Fixed %d of %d clone groups", fixed, len(dupls))
+	}
+	for _, f := range fixer.Files() {
+		if !*write {
+			os.Stdout.Write(diff.Unified(f.Filename+".orig", f.Filename, f.Src, f.Fixed))
+			continue
+		}
+		info, err := os.Stat(f.Filename)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(f.Filename, f.Fixed, info.Mode()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
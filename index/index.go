@@ -0,0 +1,83 @@
+// Package index stores the serialized syntax trees of a reference corpus
+// so that they can be searched for clones of other code without parsing
+// the corpus again.
+package index
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"io"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// An Index holds the files of a reference corpus.
+type Index struct {
+	// Level and Normalize are the settings of the Go frontend the files
+	// were parsed with, see golang.Config.
+	Level     int
+	Normalize int
+
+	// Suppress describes the suppressors of the files excluded from
+	// the search, which are set by the caller.
+	Suppress string
+
+	Files []File
+}
+
+// A File is a serialized syntax tree of a file along with its source.
+type File struct {
+	Filename string
+	Src      []byte
+	Nodes    []Node
+}
+
+// A Node is a node of the serialized syntax tree.
+type Node struct {
+	Type, Pos, End, Owns int
+}
+
+// Add adds the file of the given source serialized to seq to the index.
+func (idx *Index) Add(filename string, src []byte, seq []*syntax.Node) {
+	f := File{Filename: filename, Src: src, Nodes: make([]Node, len(seq))}
+	for i, n := range seq {
+		f.Nodes[i] = Node{n.Type, n.Pos, n.End, n.Owns}
+	}
+	idx.Files = append(idx.Files, f)
+}
+
+// Seq returns the serialized syntax tree of the file.
+func (f *File) Seq() []*syntax.Node {
+	seq := make([]*syntax.Node, len(f.Nodes))
+	for i, n := range f.Nodes {
+		seq[i] = &syntax.Node{Type: n.Type, Filename: f.Filename, Pos: n.Pos, End: n.End, Owns: n.Owns}
+	}
+	for i, n := range seq {
+		for j := i + 1; j <= i+n.Owns; j += seq[j].Owns + 1 {
+			n.Children = append(n.Children, seq[j])
+		}
+	}
+	return seq
+}
+
+// Write writes the index to w.
+func (idx *Index) Write(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	if err := gob.NewEncoder(zw).Encode(idx); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Read reads an index written by Write from r.
+func Read(r io.Reader) (*Index, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	idx := new(Index)
+	if err := gob.NewDecoder(zr).Decode(idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
@@ -0,0 +1,37 @@
+package index
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+func TestIndex(t *testing.T) {
+	nodes := make([]*syntax.Node, 5)
+	for i := range nodes {
+		nodes[i] = &syntax.Node{Type: i, Filename: "a.go", Pos: i, End: 10 - i}
+	}
+	nodes[0].AddChildren(nodes[1], nodes[3])
+	nodes[1].AddChildren(nodes[2])
+	nodes[3].AddChildren(nodes[4])
+	seq := syntax.Serialize(nodes[0])
+
+	idx := &Index{Level: 1, Suppress: "errchecks"}
+	idx.Add("a.go", []byte("package a"), seq)
+	var buf bytes.Buffer
+	if err := idx.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, idx) {
+		t.Fatalf("got %+v, want %+v", read, idx)
+	}
+	if actual := read.Files[0].Seq(); !reflect.DeepEqual(actual, seq) {
+		t.Errorf("got %v, want %v", actual, seq)
+	}
+}
@@ -5,12 +5,22 @@ import (
 	"github.com/mibk/dupl/syntax"
 )
 
-func BuildTree(schan chan []*syntax.Node) (t *suffixtree.STree, d *[]*syntax.Node, done chan bool) {
+// BuildTree builds the suffix tree of the sequences of nodes of the files.
+// If separate is set, each file is ended with a unique node of a negative
+// type so that no match crosses the boundaries of files.
+func BuildTree(schan chan []*syntax.Node, separate bool) (t *suffixtree.STree, d *[]*syntax.Node, done chan bool) {
 	t = suffixtree.New()
 	data := make([]*syntax.Node, 0, 100)
 	done = make(chan bool)
 	go func() {
-		for seq := range schan {
+		for i := 0; ; i++ {
+			seq, ok := <-schan
+			if !ok {
+				break
+			}
+			if separate {
+				seq = append(seq, &syntax.Node{Type: -2 - i})
+			}
 			data = append(data, seq...)
 			for _, node := range seq {
 				t.Update(node)
@@ -0,0 +1,42 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+func TestBuildTree(t *testing.T) {
+	testCases := []struct {
+		separate bool
+		types    []int
+	}{
+		{false, []int{1, 2, 1, 2}},
+		{true, []int{1, 2, -2, 1, 2, -3}},
+	}
+	for _, tc := range testCases {
+		schan := make(chan []*syntax.Node)
+		go func() {
+			for i := 0; i < 2; i++ {
+				schan <- []*syntax.Node{{Type: 1}, {Type: 2}}
+			}
+			close(schan)
+		}()
+		_, data, done := BuildTree(schan, tc.separate)
+		<-done
+		var types []int
+		for _, n := range *data {
+			types = append(types, n.Type)
+		}
+		if len(types) != len(tc.types) {
+			t.Errorf("separate=%v: got %v, want %v", tc.separate, types, tc.types)
+			continue
+		}
+		for i := range types {
+			if types[i] != tc.types[i] {
+				t.Errorf("separate=%v: got %v, want %v", tc.separate, types, tc.types)
+				break
+			}
+		}
+	}
+}
@@ -2,23 +2,28 @@ package job
 
 import (
 	"log"
+	"path/filepath"
 
 	"github.com/mibk/dupl/syntax"
 	"github.com/mibk/dupl/syntax/golang"
 )
 
-func Parse(fchan chan string) chan []*syntax.Node {
+func Parse(fchan chan string, cfg *golang.Config) chan []*syntax.Node {
 
 	// parse AST
 	achan := make(chan *syntax.Node)
 	go func() {
-		for file := range fchan {
-			ast, err := golang.Parse(file)
-			if err != nil {
-				log.Println(err)
-				continue
+		if cfg.TypeCheck {
+			parsePackages(fchan, cfg, achan)
+		} else {
+			for file := range fchan {
+				ast, err := cfg.Parse(file)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+				achan <- ast
 			}
-			achan <- ast
 		}
 		close(achan)
 	}()
@@ -34,3 +39,26 @@ func Parse(fchan chan string) chan []*syntax.Node {
 	}()
 	return schan
 }
+
+// parsePackages parses the files grouped by their directories so that
+// each package can be type-checked as a whole.
+func parsePackages(fchan chan string, cfg *golang.Config, achan chan *syntax.Node) {
+	var dirs []string
+	pkgs := make(map[string][]string)
+	for file := range fchan {
+		dir := filepath.Dir(file)
+		if _, ok := pkgs[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		pkgs[dir] = append(pkgs[dir], file)
+	}
+	for _, dir := range dirs {
+		asts, err := cfg.ParsePackage(pkgs[dir])
+		if err != nil {
+			log.Println(err)
+		}
+		for _, ast := range asts {
+			achan <- ast
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// findLiterals returns the string and numeric literals of at least
+// -literal-len characters occurring at least -literal-count times ranked
+// by the number of their occurrences.
+func findLiterals(data []*syntax.Node) ([]syntax.Match, error) {
+	files := make(fileCache)
+	groups := make(map[string]*syntax.Match)
+	var lits []*syntax.Match
+	for _, n := range data {
+		// the nodes terminating files have no filenames and the files
+		// in the reference index are not searched
+		if n.Filename == "" || n.Corpus == refCorpus || golang.Kind(n.Type) != golang.BasicLit {
+			continue
+		}
+		file, err := files.read(n.Filename)
+		if err != nil {
+			return nil, err
+		}
+		lit := string(fragSrc([]*syntax.Node{n}, file))
+		value, length, ok := golang.LiteralValue(lit)
+		if !ok || length < *literalLen {
+			continue
+		}
+		m, ok := groups[value]
+		if !ok {
+			m = &syntax.Match{Hash: "literal " + value, Similarity: 1, Literal: lit}
+			groups[value] = m
+			lits = append(lits, m)
+		}
+		m.Frags = append(m.Frags, []*syntax.Node{n})
+	}
+
+	var dupls []syntax.Match
+	for _, m := range lits {
+		if len(m.Frags) >= *literalCount {
+			dupls = append(dupls, *m)
+		}
+	}
+	sort.SliceStable(dupls, func(i, j int) bool { return len(dupls[i].Frags) > len(dupls[j].Frags) })
+	return dupls, nil
+}
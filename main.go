@@ -8,25 +8,73 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 
 	"github.com/mibk/dupl/job"
 	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/suffixtree"
 	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
 )
 
-const defaultThreshold = 100
+const (
+	defaultThreshold = 100
+
+	// Each part of a near-miss clone must be at least
+	// 1/nearMissParts of the threshold long.
+	nearMissParts = 4
+)
 
 var (
 	paths     = []string{"."}
 	vendor    = flag.Bool("vendor", false, "")
 	verbose   = flag.Bool("verbose", false, "")
 	threshold = flag.Int("threshold", defaultThreshold, "")
+	minLines  = flag.Int("min-lines", 0, "")
+	minStmts  = flag.Int("min-stmts", 0, "")
+	minKinds  = flag.Int("min-kinds", 0, "")
+	weights   = flag.String("weights", "", "")
 	files     = flag.Bool("files", false, "")
+	gap       = flag.Int("gap", 0, "")
+	gapStmts  = flag.Int("gap-stmts", 0, "")
+	level     = flag.Int("level", golang.Kinds, "")
+	normalize = flag.String("normalize", "", "")
+	typeCheck = flag.Bool("types", false, "")
+
+	suppress     = flag.String("suppress", "", "")
+	suppressFile = flag.String("suppress-file", "", "")
+
+	fix            = flag.Bool("fix", false, "")
+	decls          = flag.Bool("decls", false, "")
+	declSimilarity = flag.Int("decl-similarity", 80, "")
+	funcs          = flag.Bool("funcs", false, "")
+	funcSimilarity = flag.Int("func-similarity", 90, "")
+	literals       = flag.Bool("literals", false, "")
+	literalCount   = flag.Int("literal-count", 3, "")
+	literalLen     = flag.Int("literal-len", 4, "")
+	methods        = flag.Bool("methods", false, "")
+	minSimilarity  = flag.Int("min-similarity", 0, "")
+	nest           = flag.Bool("nest", false, "")
+	renames        = flag.Bool("renames", false, "")
+	scope          = flag.String("scope", "", "")
+	submissions    = flag.Bool("submissions", false, "")
+	suggest        = flag.Bool("suggest", false, "")
+	suspicious     = flag.Bool("suspicious", false, "")
+	tests          = flag.Bool("tests", false, "")
+	textSimilarity = flag.Bool("text-similarity", false, "")
 
 	html     = flag.Bool("html", false, "")
+	jsonOut  = flag.Bool("json", false, "")
 	plumbing = flag.Bool("plumbing", false, "")
+	write    = flag.Bool("w", false, "")
+
+	ref    = flag.String("ref", "", "")
+	output = flag.String("o", "", "")
+
+	compare    = new(bool) // set by the compare command
+	indexBuild = new(bool) // set by the index build command
 )
 
 const (
@@ -41,52 +89,255 @@ func init() {
 
 func main() {
 	flag.Usage = usage
-	flag.Parse()
-	if *html && *plumbing {
-		log.Fatal("you can have either plumbing or HTML output")
+	args := os.Args[1:]
+	switch {
+	case len(args) > 0 && args[0] == "compare":
+		*compare = true
+		args = args[1:]
+	case len(args) > 1 && args[0] == "index" && args[1] == "build":
+		*indexBuild = true
+		args = parseInterleaved(args[2:])
+	}
+	flag.CommandLine.Parse(args)
+	if *compare && (flag.NArg() != 2 || *files) ||
+		*indexBuild && (*output == "" || *ref != "") || *write && !*fix {
+		usage()
+	}
+	if err := checkModes(setModes()); err != nil {
+		log.Fatal(err)
+	}
+	if *scope != "" && !scopes[*scope] {
+		log.Fatalf("unknown scope %q", *scope)
 	}
 	if flag.NArg() > 0 {
 		paths = flag.Args()
 	}
+	if *submissions {
+		var err error
+		if paths, err = listSubmissions(paths); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	if *verbose {
 		log.Println("Building suffix tree")
 	}
-	schan := job.Parse(filesFeed())
-	t, data, done := job.BuildTree(schan)
+	cfg := &golang.Config{Level: *level, TypeCheck: *typeCheck}
+	if *normalize != "" {
+		for _, name := range strings.Split(*normalize, ",") {
+			rule, ok := golang.Normalizations[name]
+			if !ok {
+				log.Fatalf("unknown normalization rule %q", name)
+			}
+			cfg.Normalize |= rule
+		}
+	}
+	if err := setSuppressors(cfg); err != nil {
+		log.Fatal(err)
+	}
+	if *indexBuild {
+		if err := buildIndex(cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	schan := job.Parse(filesFeed(cfg), cfg)
+	if *ref != "" {
+		idx, err := readIndex(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		schan = withReference(schan, idx)
+	}
+	t, data, done := job.BuildTree(schan, *compare || *submissions)
 	<-done
 
 	// finish stream
 	t.Update(&syntax.Node{Type: -1})
 
+	newPrinter := printer.NewText
+	if *html {
+		newPrinter = printer.NewHTML
+	} else if *jsonOut {
+		newPrinter = printer.NewJSON
+	} else if *plumbing {
+		newPrinter = printer.NewPlumbing
+	}
+	p := newPrinter(os.Stdout, readFile)
+
+	if *literals || *decls || *suspicious {
+		var found []syntax.Match
+		var err error
+		switch {
+		case *literals:
+			found, err = findLiterals(*data)
+		case *decls:
+			found, err = findDecls(*data, cfg)
+		default:
+			found, err = findSuspects(*data)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := printDupls(p, found, cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if *verbose {
 		log.Println("Searching for clones")
 	}
-	mchan := t.FindDuplOver(*threshold)
+	var w syntax.Weights
+	if *weights != "" {
+		var err error
+		if w, err = readWeights(*weights); err != nil {
+			log.Fatal(err)
+		}
+	}
+	var dupls []syntax.Match
+	if *methods {
+		dupls = findMethods(*data, cfg, w)
+	} else if *funcs {
+		minSimilarity := float64(*funcSimilarity) / 100
+		dupls = syntax.FindSimilarUnits(*data, golang.IsFunc, *threshold, w, minSimilarity)
+	} else {
+		dupls = groupDupls(findDupls(t, *data, w))
+		if w != nil {
+			// rank the clones by their weighted size
+			sort.SliceStable(dupls, func(i, j int) bool { return dupls[i].Size > dupls[j].Size })
+		}
+	}
+	dupls, err := filterDupls(dupls, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *submissions {
+		printMatrix := printer.PrintMatrix
+		if *html {
+			printMatrix = printer.PrintMatrixHTML
+		}
+		pairs := submissionPairs(dupls, *data, len(paths))
+		if err := printMatrix(os.Stdout, readFile, paths, pairs); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *fix {
+		if err := fixDupls(dupls, cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *nest {
+		dupls = syntax.Nest(dupls)
+	}
+	if err := printDupls(p, dupls, cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseInterleaved parses the flags interleaved with the arguments and
+// returns the arguments, followed by the -- terminator so that the
+// arguments are not parsed as flags again.
+func parseInterleaved(args []string) []string {
+	var rest []string
+	for {
+		flag.CommandLine.Parse(args)
+		if args = flag.Args(); len(args) == 0 {
+			break
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+	return append([]string{"--"}, rest...)
+}
+
+// setSuppressors sets the suppressors given by the -suppress and
+// -suppress-file flags.
+func setSuppressors(cfg *golang.Config) error {
+	if *suppress != "" {
+		for _, name := range strings.Split(*suppress, ",") {
+			if s, ok := golang.Suppressors[name]; ok {
+				cfg.Suppress = append(cfg.Suppress, s)
+			} else if kind, ok := golang.KindByName(name); ok {
+				cfg.Suppress = append(cfg.Suppress, golang.SuppressKind(kind))
+			} else {
+				return fmt.Errorf("unknown suppressor %q", name)
+			}
+		}
+	}
+	if *suppressFile != "" {
+		src, err := ioutil.ReadFile(*suppressFile)
+		if err != nil {
+			return err
+		}
+		// the templates are separated by blank lines
+		for _, snippet := range regexp.MustCompile(`\n\s*\n`).Split(string(src), -1) {
+			if strings.TrimSpace(snippet) == "" {
+				continue
+			}
+			s, err := cfg.SuppressTemplate(snippet)
+			if err != nil {
+				return fmt.Errorf("%s: %v", *suppressFile, err)
+			}
+			cfg.Suppress = append(cfg.Suppress, s)
+		}
+	}
+	return nil
+}
+
+func readWeights(filename string) (syntax.Weights, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	w, err := golang.ParseWeights(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return w, nil
+}
+
+func findDupls(t *suffixtree.STree, data []*syntax.Node, w syntax.Weights) <-chan syntax.Match {
+	minLen := *threshold
+	if w != nil {
+		// heavier nodes make clones of fewer tokens reach the threshold
+		minLen = int(float64(minLen) / golang.MaxWeight(w))
+	}
+	if *gap > 0 {
+		// near-miss clones are chained from shorter parts
+		minLen /= nearMissParts
+	}
+	if minLen < 1 {
+		// every position would match
+		minLen = 1
+	}
+	mchan := t.FindDuplOver(minLen)
 	duplChan := make(chan syntax.Match)
 	go func() {
+		var ms []suffixtree.Match
 		for m := range mchan {
-			match := syntax.FindSyntaxUnits(*data, m, *threshold)
+			if *gap > 0 {
+				ms = append(ms, m)
+			}
+			match := syntax.FindSyntaxUnits(data, m, *threshold, w)
 			if len(match.Frags) > 0 {
 				duplChan <- match
 			}
 		}
+		if *gap > 0 {
+			for _, match := range syntax.FindNearMisses(data, ms, *threshold, w, *gap, *gapStmts) {
+				duplChan <- match
+			}
+		}
 		close(duplChan)
 	}()
-
-	newPrinter := printer.NewText
-	if *html {
-		newPrinter = printer.NewHTML
-	} else if *plumbing {
-		newPrinter = printer.NewPlumbing
-	}
-	p := newPrinter(os.Stdout, ioutil.ReadFile)
-	if err := printDupls(p, duplChan); err != nil {
-		log.Fatal(err)
-	}
+	return duplChan
 }
 
-func filesFeed() chan string {
+func filesFeed(cfg *golang.Config) chan string {
 	if *files {
 		fchan := make(chan string)
 		go func() {
@@ -99,18 +350,21 @@ func filesFeed() chan string {
 		}()
 		return fchan
 	}
-	return crawlPaths(paths)
+	return crawlPaths(paths, cfg)
 }
 
-func crawlPaths(paths []string) chan string {
+// crawlPaths sends the Go files in the paths. In compare and submissions
+// mode, the files are assigned the corpora of the indexes of the paths.
+func crawlPaths(paths []string, cfg *golang.Config) chan string {
 	fchan := make(chan string)
 	go func() {
-		for _, path := range paths {
+		for i, path := range paths {
 			info, err := os.Lstat(path)
 			if err != nil {
 				log.Fatal(err)
 			}
 			if !info.IsDir() {
+				setCorpus(cfg, path, i)
 				fchan <- path
 				continue
 			}
@@ -120,6 +374,7 @@ func crawlPaths(paths []string) chan string {
 					return nil
 				}
 				if !info.IsDir() && strings.HasSuffix(info.Name(), ".go") {
+					setCorpus(cfg, path, i)
 					fchan <- path
 				}
 				return nil
@@ -133,10 +388,14 @@ func crawlPaths(paths []string) chan string {
 	return fchan
 }
 
-func printDupls(p printer.Printer, duplChan <-chan syntax.Match) error {
-	groups := make(map[string][][]*syntax.Node)
+// groupDupls groups the clones with the same hash together.
+func groupDupls(duplChan <-chan syntax.Match) []syntax.Match {
+	groups := make(map[string]syntax.Match)
 	for dupl := range duplChan {
-		groups[dupl.Hash] = append(groups[dupl.Hash], dupl.Frags...)
+		if g, ok := groups[dupl.Hash]; ok {
+			dupl.Frags = append(g.Frags, dupl.Frags...)
+		}
+		groups[dupl.Hash] = dupl
 	}
 	keys := make([]string, 0, len(groups))
 	for k := range groups {
@@ -144,18 +403,42 @@ func printDupls(p printer.Printer, duplChan <-chan syntax.Match) error {
 	}
 	sort.Strings(keys)
 
+	var dupls []syntax.Match
+	for _, k := range keys {
+		g := groups[k]
+		g.Frags = unique(g.Frags)
+		if len(g.Frags) > 1 {
+			dupls = append(dupls, g)
+		}
+	}
+	return dupls
+}
+
+// printDupls prints the clone groups labeled by the declarations enclosing
+// the clones.
+func printDupls(p printer.Printer, dupls []syntax.Match, cfg *golang.Config) error {
 	if err := p.PrintHeader(); err != nil {
 		return err
 	}
-	for _, k := range keys {
-		uniq := unique(groups[k])
-		if len(uniq) > 1 {
-			if err := p.PrintClones(uniq); err != nil {
-				return err
-			}
+	for _, dupl := range dupls {
+		setEnclosing(&dupl, cfg)
+		if err := p.PrintClones(dupl); err != nil {
+			return err
 		}
 	}
-	return p.PrintFooter()
+	return p.PrintFooter(printer.Summary{Suppressed: cfg.Suppressed()})
+}
+
+// setEnclosing sets the labels of the declarations enclosing the clones
+// of the group and of the groups nested in it.
+func setEnclosing(dupl *syntax.Match, cfg *golang.Config) {
+	dupl.Enclosing = make([]string, len(dupl.Frags))
+	for i, frag := range dupl.Frags {
+		dupl.Enclosing[i] = cfg.Enclosing(frag[0].Filename, frag[0].Pos)
+	}
+	for i := range dupl.Nested {
+		setEnclosing(&dupl.Nested[i], cfg)
+	}
 }
 
 func unique(group [][]*syntax.Node) [][]*syntax.Node {
@@ -179,6 +462,8 @@ func unique(group [][]*syntax.Node) [][]*syntax.Node {
 
 func usage() {
 	fmt.Fprintln(os.Stderr, `Usage: dupl [flags] [paths]
+       dupl compare [flags] pathA pathB
+       dupl index build [flags] paths -o file
 
 Paths:
   If the given path is a file, dupl will use it regardless of
@@ -188,19 +473,139 @@ Paths:
   If no path is given, dupl will recursively search for *.go
   files in the current directory.
 
+  The compare command reports only clone groups with at least one
+  clone in each of the paths, e.g. code copied from pathA to pathB.
+
+  The index build command writes the syntax trees of the files in
+  the paths to the index file given by -o to be used with -ref.
+
 Flags:
+  -decl-similarity pct
+    	minimum percentage of the same fields of each pair of struct
+    	types reported together by -decls (default 80)
+  -decls
+    	report struct types with the same or similar fields, including
+    	their types and tags, and interfaces with the same methods
+    	declared in several packages instead of clones
   -files
     	read file names from stdin one at each line
+  -fix
+    	rewrite the clone groups into calls of functions extracted from
+    	them, see -suggest, if the clones are in a single package that
+    	still type-checks afterwards, and print the diffs of the files
+  -func-similarity pct
+    	minimum similarity in percent of functions reported by -funcs
+    	and -methods (default 90)
+  -funcs
+    	report identical and similar functions and methods ranked by
+    	their similarity and size instead of token sequences
+  -gap size
+    	find also near-miss clones whose parts are separated by gaps
+    	of at most size tokens
+  -gap-stmts n
+    	limit the gaps of near-miss clones to at most n statements
   -html
     	output the results as HTML, including duplicate code fragments
+  -json
+    	output the results as JSON
+  -level n
+    	level of detail distinguished in tokens: 0 compares only kinds
+    	of nodes, 1 also operators, 2 also keywords, literal kinds and
+    	channel directions (default 0)
+  -literal-count n
+    	minimum number of occurrences of literals reported by -literals
+    	(default 3)
+  -literal-len n
+    	minimum length in characters of literals reported by -literals,
+    	excluding quotes (default 4)
+  -literals
+    	report string and numeric literals repeated in the code, such as
+    	SQL fragments, error messages, URLs or magic numbers, instead
+    	of clones
+  -methods
+    	report methods of the same names and signatures on different
+    	receiver types whose bodies are clones, grouped by the method
+    	names, along with suggestions to share their implementation
+  -min-kinds n
+    	minimum number of distinct node kinds in a clone
+  -min-lines n
+    	minimum number of source lines of a clone
+  -min-similarity pct
+    	report only clone groups whose fragments are on average at least
+    	pct percent textually similar
+  -min-stmts n
+    	minimum number of statements in a clone
+  -nest
+    	nest clone groups whose clones are all contained in clones
+    	of a larger group with at least as many clones
+  -normalize rules
+    	comma-separated list of normalization rules applied before
+    	comparing: parens (drop parentheses), qualifiers (treat pkg.Name
+    	as Name), conversions (treat type conversions as their operands),
+    	loops (treat for i := 0; i < n; i++ as for i := range n)
+  -o file
+    	write the index built by dupl index build to file
   -plumbing
     	plumbing (easy-to-parse) output for consumption by scripts or tools
+  -ref file
+    	report only clone groups with at least one clone in the files
+    	of the reference index file built by dupl index build with
+    	the same -level, -normalize, -suppress and -suppress-file flags;
+    	the files in the index are reported as file:path and belong
+    	to none of the compared paths or submissions
+  -renames
+    	warn about identifiers in clones that are not renamed although
+    	their other occurrences are, likely copy-paste bugs
+  -scope scope
+    	report only clone groups within the same file (file) or
+    	spanning different files (files), packages (packages)
+    	or modules with their own go.mod (modules)
+  -submissions
+    	treat each top-level directory in the paths as a submission and
+    	report the percentages of tokens of each submission duplicated
+    	in the others along with the largest duplicated fragments;
+    	clones within a single submission are ignored
+  -suggest
+    	suggest refactorings of the clones: generic functions replacing
+    	functions that differ only in types, functions extracted from
+    	the cloned statements along with the calls replacing them and
+    	table-driven tests replacing test functions
+  -suspicious
+    	report likely copy-paste bugs instead of clones: identical
+    	branches of if-else chains, identical bodies of cases of switch
+    	and select statements and identical operands of binary
+    	expressions such as x == y && x == y
+  -suppress list
+    	comma-separated list of boilerplate patterns excluded from
+    	the search: errchecks (if err != nil { return ..., err }),
+    	testtables (slices of test cases), maplits (map literals),
+    	varblocks (var (...) declarations) or names of node kinds
+    	such as CompositeLit
+  -suppress-file file
+    	exclude from the search code matching the Go snippets in file;
+    	the snippets are separated by blank lines and identifiers _
+    	in them match any code
   -t, -threshold size
     	minimum token sequence size as a clone (default 100)
+  -tests
+    	report only clone groups of test functions in _test.go files
+    	along with table-driven tests that could replace them
+  -text-similarity
+    	report the textual similarity of each clone group and of each
+    	pair of its clones
+  -types
+    	type-check packages and distinguish identifiers and calls
+    	by their resolved objects and types
   -vendor
     	check files in vendor directory
   -v, -verbose
     	explain what is being done
+  -w
+    	write the files rewritten by -fix instead of printing the diffs
+  -weights file
+    	read weights of node kinds used to compute the size of clones
+    	from file, e.g. CompositeLit=0.2, KeyValueExpr=0.1, IfStmt=2;
+    	the clones are then ranked by their weighted size
 
 Examples:
   dupl -t 200
@@ -209,6 +614,33 @@ Examples:
   dupl $(find app/ -name '*_test.go')
     	Search for clones in tests in the app directory.
   find app/ -name '*_test.go' |dupl -files
-    	The same as above.`)
+    	The same as above.
+  dupl -gap 30 -gap-stmts 2
+    	Search also for clones with up to 2 added, removed or
+    	modified statements between the matching parts.
+  dupl -t 50 -min-lines 10 -min-stmts 5
+    	Search clones of size at least 50 tokens spanning at least
+    	10 lines and 5 statements.
+  dupl -funcs -func-similarity 80 -t 50
+    	List functions of size at least 50 tokens that are at least
+    	80 % similar to some other function.
+  dupl -methods -t 50
+    	List methods of size at least 50 tokens cloned on several
+    	receiver types.
+  dupl -literals -literal-len 20
+    	List string and numeric literals of at least 20 characters
+    	occurring at least 3 times.
+  dupl compare -t 50 upstream/ fork/
+    	Search for code of the upstream directory that is still
+    	duplicated in the fork directory.
+  dupl -submissions -html -t 30 exercise/ >report.html
+    	Compare the submissions in the subdirectories of the exercise
+    	directory with each other.
+  dupl -fix -w -t 50 app/
+    	Replace clones of size at least 50 tokens in the app directory
+    	by calls of new functions where possible.
+  dupl index build $(go env GOROOT)/src/{bytes,sort,strings} -o std.idx
+    	Index some packages of the standard library; dupl -ref std.idx
+    	then searches for code copied from them.`)
 	os.Exit(2)
 }
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+type method struct {
+	golang.Method
+	frag []*syntax.Node
+}
+
+// findMethods returns the groups of methods of the same names and signatures
+// declared on different receiver types whose bodies are clones, at least
+// -func-similarity percent similar, grouped by the method names.
+func findMethods(data []*syntax.Node, cfg *golang.Config, w syntax.Weights) []syntax.Match {
+	isMethod := func(n *syntax.Node) bool {
+		_, ok := cfg.Method(n.Filename, n.Pos)
+		return ok && golang.Kind(n.Type) == golang.FuncDecl
+	}
+	minSimilarity := float64(*funcSimilarity) / 100
+	units := syntax.FindSimilarUnits(data, isMethod, *threshold, w, minSimilarity)
+
+	// join the clones of the same methods, the clones of other methods
+	// do not make them similar
+	var methods []method
+	index := make(map[*syntax.Node]int)
+	var parent []int
+	var sims []float64
+	var root func(i int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+	for _, m := range units {
+		firsts := make(map[string]int) // by the names and signatures
+		nodes := make(map[string]*syntax.Node)
+		differ := make(map[string]bool)
+		for _, frag := range m.Frags {
+			i, ok := index[frag[0]]
+			if !ok {
+				meth, _ := cfg.Method(frag[0].Filename, frag[0].Pos)
+				i = len(methods)
+				index[frag[0]] = i
+				methods = append(methods, method{meth, frag})
+				parent = append(parent, i)
+				sims = append(sims, 1)
+			}
+			key := methods[i].Name + methods[i].Signature
+			first, ok := firsts[key]
+			if !ok {
+				first = root(i)
+				firsts[key], nodes[key] = first, frag[0]
+			} else if !identical(frag[0], nodes[key]) {
+				differ[key] = true
+			}
+			if r := root(i); r != first {
+				parent[r] = first
+				if sims[r] < sims[first] {
+					sims[first] = sims[r]
+				}
+			}
+		}
+		// the clones of a method are as similar as the match only if
+		// they are not identical
+		for key, first := range firsts {
+			if differ[key] && m.Similarity < sims[first] {
+				sims[first] = m.Similarity
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	var roots []int
+	for i := range methods {
+		r := root(i)
+		if _, ok := groups[r]; !ok {
+			roots = append(roots, r)
+		}
+		groups[r] = append(groups[r], i)
+	}
+	var dupls []syntax.Match
+	for _, r := range roots {
+		group := groups[r]
+		var recvs []string
+		seen := make(map[string]bool)
+		for _, i := range group {
+			if recv := methods[i].Recv; !seen[recv] {
+				seen[recv] = true
+				recvs = append(recvs, recv)
+			}
+		}
+		if len(recvs) < 2 {
+			continue
+		}
+		first := methods[group[0]]
+		m := syntax.Match{
+			Hash:       fmt.Sprintf("method %s %d", first.frag[0].Filename, first.frag[0].Pos),
+			Similarity: sims[r],
+			Method:     first.Name + first.Signature,
+			Suggestions: []string{fmt.Sprintf("embed a type implementing %s in %s or call a shared generic helper",
+				first.Name, joinNames(recvs))},
+		}
+		for _, i := range group {
+			m.Frags = append(m.Frags, methods[i].frag)
+			if size := float64(methods[i].frag[0].Owns + 1); size > m.Size {
+				m.Size = size
+			}
+		}
+		dupls = append(dupls, m)
+	}
+	sort.SliceStable(dupls, func(i, j int) bool { return dupls[i].Method < dupls[j].Method })
+	return dupls
+}
+
+// identical reports whether the trees of the nodes are of the same types.
+func identical(a, b *syntax.Node) bool {
+	if a.Type != b.Type || len(a.Children) != len(b.Children) {
+		return false
+	}
+	for i := range a.Children {
+		if !identical(a.Children[i], b.Children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// joinNames joins the names as an enumeration, e.g. "A, B and C".
+func joinNames(names []string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
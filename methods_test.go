@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+func TestFindMethods(t *testing.T) {
+	files := writeFiles(t, map[string]string{
+		"p.go": `package p
+
+type A []int
+type B []int
+type C []int
+
+func (a A) Sum() int {
+	n := 0
+	for _, x := range a {
+		n += x
+	}
+	return n
+}
+
+func (b B) Sum() int {
+	n := 0
+	for _, x := range b {
+		n += x
+	}
+	return n
+}
+
+func (c C) Total() int {
+	n := 0
+	for _, x := range c {
+		n += x
+	}
+	n++
+	return n
+}
+`,
+	})
+	cfg := new(golang.Config)
+	root, err := cfg.Parse(files["p.go"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := syntax.Serialize(root)
+
+	defer func(old int) { *funcSimilarity = old }(*funcSimilarity)
+	defer func(old int) { *threshold = old }(*threshold)
+	*funcSimilarity, *threshold = 50, 10
+	dupls := findMethods(data, cfg, nil)
+	if len(dupls) != 1 {
+		t.Fatalf("got %d groups, want 1", len(dupls))
+	}
+	m := dupls[0]
+	if m.Method != "Sum() int" || len(m.Frags) != 2 {
+		t.Errorf("got %d clones of %s, want 2 clones of Sum() int", len(m.Frags), m.Method)
+	}
+	// Total is similar to the Sums but it does not make them less similar
+	if m.Similarity != 1 {
+		t.Errorf("got similarity %v, want 1", m.Similarity)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import "fmt"
+
+// exclusiveModes are the groups of modes of which at most one can be set,
+// the others would be ignored.
+var exclusiveModes = [][]string{
+	{"-html", "-json", "-plumbing"},
+	{"compare", "-submissions", "index build"},
+	{"-literals", "-decls", "-suspicious", "-methods", "-funcs", "index build"},
+	{"-literals", "-decls", "-suspicious", "compare"},
+	{"-literals", "-decls", "-suspicious", "-submissions", "-fix", "-nest", "index build"},
+	{"-fix", "-submissions", "-json", "-plumbing"},
+	{"-fix", "-html"},
+}
+
+// setModes returns the modes set by the command and the flags.
+func setModes() map[string]bool {
+	return map[string]bool{
+		"compare":      *compare,
+		"index build":  *indexBuild,
+		"-submissions": *submissions,
+		"-literals":    *literals,
+		"-decls":       *decls,
+		"-suspicious":  *suspicious,
+		"-methods":     *methods,
+		"-funcs":       *funcs,
+		"-fix":         *fix,
+		"-nest":        *nest,
+		"-html":        *html,
+		"-json":        *jsonOut,
+		"-plumbing":    *plumbing,
+	}
+}
+
+// checkModes returns an error if more than one mode of a group
+// of exclusive modes is set.
+func checkModes(set map[string]bool) error {
+	for _, group := range exclusiveModes {
+		var conflicting []string
+		for _, mode := range group {
+			if set[mode] {
+				conflicting = append(conflicting, mode)
+			}
+		}
+		if len(conflicting) > 1 {
+			return fmt.Errorf("you can have only one of %s", joinNames(conflicting))
+		}
+	}
+	return nil
+}
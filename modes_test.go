@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestCheckModes(t *testing.T) {
+	testCases := []struct {
+		modes []string
+		ok    bool
+	}{
+		{nil, true},
+		{[]string{"-html"}, true},
+		{[]string{"-html", "-json"}, false},
+		{[]string{"-html", "-submissions"}, true},
+		{[]string{"-json", "-submissions"}, false},
+		{[]string{"compare", "-methods", "-nest"}, true},
+		{[]string{"compare", "-submissions"}, false},
+		{[]string{"compare", "-literals"}, false},
+		{[]string{"-literals", "-decls"}, false},
+		{[]string{"-decls", "-suspicious"}, false},
+		{[]string{"-methods", "-funcs"}, false},
+		{[]string{"-funcs", "-submissions"}, true},
+		{[]string{"-suspicious", "-nest"}, false},
+		{[]string{"-fix", "-submissions"}, false},
+		{[]string{"-fix", "-nest"}, false},
+		{[]string{"-fix", "-methods"}, true},
+		{[]string{"-fix", "-html"}, false},
+		{[]string{"-submissions", "-nest"}, false},
+		{[]string{"index build", "-funcs"}, false},
+	}
+	for _, tc := range testCases {
+		set := make(map[string]bool)
+		for _, mode := range tc.modes {
+			set[mode] = true
+		}
+		if err := checkModes(set); (err == nil) != tc.ok {
+			t.Errorf("%v: got error %v", tc.modes, err)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"regexp"
 	"sort"
+	"strings"
 
 	"github.com/mibk/dupl/syntax"
 )
@@ -43,6 +44,24 @@ func (p *htmlprinter) PrintHeader() error {
 		font-weight: normal;
 		color: #555;
 	}
+	.nested {
+		margin-left: 2em;
+	}
+	table {
+		font-size: 0.85em;
+		border-collapse: collapse;
+	}
+	th, td {
+		border: 1px solid #E2E2E2;
+		padding: 0.2em 0.5em;
+	}
+	th {
+		font-weight: normal;
+		color: #555;
+	}
+	td {
+		text-align: right;
+	}
 	pre {
 		tab-size: 4;
 		background-color: #FFD;
@@ -58,41 +77,103 @@ func (p *htmlprinter) PrintHeader() error {
 	return err
 }
 
-func (p *htmlprinter) PrintClones(dups [][]*syntax.Node) error {
+func (p *htmlprinter) PrintClones(m syntax.Match) error {
 	p.iota++
-	fmt.Fprintf(p.w, "<h1>#%d found %d clones</h1>\n", p.iota, len(dups))
+	return p.printClones(m, fmt.Sprint(p.iota))
+}
 
-	clones := make([]clone, len(dups))
-	for i, dup := range dups {
-		cnt := len(dup)
-		if cnt == 0 {
-			panic("zero length dup")
-		}
-		nstart := dup[0]
-		nend := dup[cnt-1]
+// printClones prints the clones labeled by the label and the clones
+// nested in them.
+func (p *htmlprinter) printClones(m syntax.Match, label string) error {
+	fmt.Fprintf(p.w, "<h1>#%s found %s%s</h1>\n", label, html.EscapeString(describe(m)), similarity(m))
 
-		file, err := p.ReadFile(nstart.Filename)
+	clones := make([]clone, len(m.Frags))
+	for i, dup := range m.Frags {
+		cl, err := p.prepareClone(dup)
 		if err != nil {
 			return err
 		}
-
-		lineStart, _ := blockLines(file, nstart.Pos, nend.End)
-		cl := clone{filename: nstart.Filename, lineStart: lineStart}
-		start := findLineBeg(file, nstart.Pos)
-		content := append(toWhitespace(file[start:nstart.Pos]), file[nstart.Pos:nend.End]...)
-		cl.fragment = deindent(content)
+		cl.index = i
 		clones[i] = cl
 	}
+	setEnclosing(clones, m)
 
 	sort.Sort(byNameAndLine(clones))
+	if m.PairSimilarity != nil {
+		p.printSimilarityTable(m, clones)
+	}
 	for _, cl := range clones {
-		fmt.Fprintf(p.w, "<h2>%s:%d</h2>\n<pre>%s</pre>\n", cl.filename, cl.lineStart,
-			html.EscapeString(string(cl.fragment)))
+		fmt.Fprintf(p.w, "<h2>%s:%d%s</h2>\n<pre>%s</pre>\n", cl.filename, cl.lineStart,
+			html.EscapeString(cl.label()), html.EscapeString(string(cl.fragment)))
+	}
+	for _, warn := range m.Warnings {
+		fmt.Fprintf(p.w, "<p>Warning: <code>%s</code></p>\n", html.EscapeString(warn))
+	}
+	for _, sugg := range m.Suggestions {
+		if strings.Contains(sugg, "\n") {
+			fmt.Fprintf(p.w, "<p>Suggestion:</p>\n<pre>%s</pre>\n", html.EscapeString(sugg))
+		} else {
+			fmt.Fprintf(p.w, "<p>Suggestion: <code>%s</code></p>\n", html.EscapeString(sugg))
+		}
+	}
+	if len(m.Nested) > 0 {
+		fmt.Fprint(p.w, "<div class=\"nested\">\n")
+		for i, nested := range m.Nested {
+			if err := p.printClones(nested, fmt.Sprintf("%s.%d", label, i+1)); err != nil {
+				return err
+			}
+		}
+		fmt.Fprint(p.w, "</div>\n")
 	}
 	return nil
 }
 
-func (*htmlprinter) PrintFooter() error { return nil }
+// prepareClone returns the clone of the fragment dup including its
+// deindented source code.
+func (p *htmlprinter) prepareClone(dup []*syntax.Node) (clone, error) {
+	cnt := len(dup)
+	if cnt == 0 {
+		panic("zero length dup")
+	}
+	file, err := p.ReadFile(dup[0].Filename)
+	if err != nil {
+		return clone{}, err
+	}
+
+	pos, end := syntax.Span(dup)
+	lineStart, lineEnd := blockLines(file, pos, end)
+	cl := clone{filename: dup[0].Filename, lineStart: lineStart, lineEnd: lineEnd}
+	start := findLineBeg(file, pos)
+	content := append(toWhitespace(file[start:pos]), file[pos:end]...)
+	cl.fragment = deindent(content)
+	return cl, nil
+}
+
+// printSimilarityTable prints the textual similarities of each pair
+// of the clones.
+func (p *htmlprinter) printSimilarityTable(m syntax.Match, clones []clone) {
+	fmt.Fprint(p.w, "<table>\n<tr><th></th>")
+	for _, cl := range clones {
+		fmt.Fprintf(p.w, "<th>%s:%d</th>", html.EscapeString(cl.filename), cl.lineStart)
+	}
+	fmt.Fprint(p.w, "</tr>\n")
+	for _, cl := range clones {
+		fmt.Fprintf(p.w, "<tr><th>%s:%d</th>", html.EscapeString(cl.filename), cl.lineStart)
+		for _, cl2 := range clones {
+			fmt.Fprintf(p.w, "<td>%d%%</td>", percent(m.PairSimilarity[cl.index][cl2.index]))
+		}
+		fmt.Fprint(p.w, "</tr>\n")
+	}
+	fmt.Fprint(p.w, "</table>\n")
+}
+
+func (p *htmlprinter) PrintFooter(s Summary) error {
+	if s.Suppressed == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintf(p.w, "<p>Suppressed %d tokens of boilerplate code.</p>\n", s.Suppressed)
+	return err
+}
 
 func findLineBeg(file []byte, index int) int {
 	for i := index; i >= 0; i-- {
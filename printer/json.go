@@ -0,0 +1,99 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+type jsonprinter struct {
+	cnt int
+	w   io.Writer
+	ReadFile
+}
+
+func NewJSON(w io.Writer, fread ReadFile) Printer {
+	return &jsonprinter{w: w, ReadFile: fread}
+}
+
+type jsonGroup struct {
+	Clones         []jsonClone `json:"clones"`
+	Similarity     float64     `json:"similarity"`
+	TextSimilarity float64     `json:"text_similarity,omitempty"`
+	Period         int         `json:"period,omitempty"`
+	Literal        string      `json:"literal,omitempty"`
+	Decl           string      `json:"decl,omitempty"`
+	Suspect        string      `json:"suspect,omitempty"`
+	Method         string      `json:"method,omitempty"`
+	Suggestions    []string    `json:"suggestions,omitempty"`
+	Warnings       []string    `json:"warnings,omitempty"`
+	Nested         []jsonGroup `json:"nested,omitempty"`
+}
+
+type jsonClone struct {
+	Filename  string `json:"filename"`
+	LineStart int    `json:"line_start"`
+	LineEnd   int    `json:"line_end"`
+	Enclosing string `json:"enclosing,omitempty"`
+}
+
+func (p *jsonprinter) PrintHeader() error {
+	_, err := fmt.Fprint(p.w, `{"groups":[`)
+	return err
+}
+
+func (p *jsonprinter) PrintClones(m syntax.Match) error {
+	g, err := p.group(m)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	if p.cnt > 0 {
+		fmt.Fprint(p.w, ",")
+	}
+	p.cnt++
+	_, err = fmt.Fprintf(p.w, "\n%s", b)
+	return err
+}
+
+func (p *jsonprinter) group(m syntax.Match) (jsonGroup, error) {
+	clones, err := prepareClonesInfo(p.ReadFile, m.Frags)
+	if err != nil {
+		return jsonGroup{}, err
+	}
+	setEnclosing(clones, m)
+	sort.Sort(byNameAndLine(clones))
+	g := jsonGroup{
+		Similarity:     m.Similarity,
+		TextSimilarity: m.TextSimilarity,
+		Period:         m.Period,
+		Literal:        m.Literal,
+		Decl:           m.Decl,
+		Suspect:        m.Suspect,
+		Method:         m.Method,
+		Suggestions:    m.Suggestions,
+		Warnings:       m.Warnings,
+	}
+	for _, cl := range clones {
+		g.Clones = append(g.Clones, jsonClone{cl.filename, cl.lineStart, cl.lineEnd, cl.enclosing})
+	}
+	for _, nested := range m.Nested {
+		ng, err := p.group(nested)
+		if err != nil {
+			return jsonGroup{}, err
+		}
+		g.Nested = append(g.Nested, ng)
+	}
+	return g, nil
+}
+
+func (p *jsonprinter) PrintFooter(s Summary) error {
+	_, err := fmt.Fprintf(p.w, "\n],\"suppressed\":%d}\n", s.Suppressed)
+	return err
+}
@@ -0,0 +1,152 @@
+package printer
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"text/tabwriter"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// A Pair describes the code duplicated between the submissions A and B.
+type Pair struct {
+	A, B int // indexes of the submissions
+
+	// Dupl holds the ratios of the tokens of A and B, respectively,
+	// duplicated in the other submission.
+	Dupl [2]float64
+
+	// Frags are the largest pairs of duplicated fragments of A and B.
+	Frags [][2][]*syntax.Node
+}
+
+// PrintMatrix prints the matrix of the percentages of the tokens of each
+// submission duplicated in the others followed by the ranked pairs of
+// submissions with their largest duplicated fragments.
+func PrintMatrix(w io.Writer, fread ReadFile, names []string, pairs []Pair) error {
+	dupl := matrix(len(names), pairs)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "\t")
+	for i := range names {
+		fmt.Fprintf(tw, "%d\t", i+1)
+	}
+	fmt.Fprintln(tw)
+	for i, name := range names {
+		fmt.Fprintf(tw, "%d %s\t", i+1, name)
+		for j := range names {
+			if i == j {
+				fmt.Fprint(tw, "-\t")
+			} else {
+				fmt.Fprintf(tw, "%d%%\t", percent(dupl[i][j]))
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, pair := range pairs {
+		if len(pair.Frags) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s and %s: %d%% and %d%% duplicated\n", names[pair.A], names[pair.B],
+			percent(pair.Dupl[0]), percent(pair.Dupl[1]))
+		for _, frags := range pair.Frags {
+			clones, err := prepareClonesInfo(fread, frags[:])
+			if err != nil {
+				return err
+			}
+			a, b := clones[0], clones[1]
+			fmt.Fprintf(w, "  %s:%d,%d and %s:%d,%d\n", a.filename, a.lineStart, a.lineEnd,
+				b.filename, b.lineStart, b.lineEnd)
+		}
+	}
+	return nil
+}
+
+// PrintMatrixHTML prints the same as PrintMatrix as HTML with the
+// duplicated fragments side by side.
+func PrintMatrixHTML(w io.Writer, fread ReadFile, names []string, pairs []Pair) error {
+	p := &htmlprinter{w: w, ReadFile: fread}
+	if err := p.PrintHeader(); err != nil {
+		return err
+	}
+	fmt.Fprint(w, `<style>
+	.side {
+		display: flex;
+		gap: 1em;
+	}
+	.side > div {
+		flex: 1;
+		min-width: 0;
+	}
+</style>
+`)
+
+	dupl := matrix(len(names), pairs)
+	fmt.Fprint(w, "<h1>Duplicated tokens</h1>\n<table>\n<tr><th></th>")
+	for i := range names {
+		fmt.Fprintf(w, "<th>%d</th>", i+1)
+	}
+	fmt.Fprint(w, "</tr>\n")
+	for i, name := range names {
+		fmt.Fprintf(w, "<tr><th>%d %s</th>", i+1, html.EscapeString(name))
+		for j := range names {
+			if i == j {
+				fmt.Fprint(w, "<td>-</td>")
+			} else {
+				fmt.Fprintf(w, "<td>%d%%</td>", percent(dupl[i][j]))
+			}
+		}
+		fmt.Fprint(w, "</tr>\n")
+	}
+	fmt.Fprint(w, "</table>\n")
+
+	fmt.Fprint(w, "<h1>Pairs</h1>\n<ol>\n")
+	for i, pair := range pairs {
+		if len(pair.Frags) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "<li><a href=\"#pair%d\">%s and %s</a>: %d%% and %d%% duplicated</li>\n", i+1,
+			html.EscapeString(names[pair.A]), html.EscapeString(names[pair.B]),
+			percent(pair.Dupl[0]), percent(pair.Dupl[1]))
+	}
+	fmt.Fprint(w, "</ol>\n")
+
+	for i, pair := range pairs {
+		if len(pair.Frags) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "<h1 id=\"pair%d\">%s and %s</h1>\n", i+1,
+			html.EscapeString(names[pair.A]), html.EscapeString(names[pair.B]))
+		for _, frags := range pair.Frags {
+			fmt.Fprint(w, "<div class=\"side\">\n")
+			for _, frag := range frags {
+				cl, err := p.prepareClone(frag)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "<div><h2>%s:%d</h2>\n<pre>%s</pre></div>\n", html.EscapeString(cl.filename),
+					cl.lineStart, html.EscapeString(string(cl.fragment)))
+			}
+			fmt.Fprint(w, "</div>\n")
+		}
+	}
+	return nil
+}
+
+// matrix returns the ratios of the tokens of each submission duplicated
+// in each other submission.
+func matrix(n int, pairs []Pair) [][]float64 {
+	dupl := make([][]float64, n)
+	for i := range dupl {
+		dupl[i] = make([]float64, n)
+	}
+	for _, pair := range pairs {
+		dupl[pair.A][pair.B] = pair.Dupl[0]
+		dupl[pair.B][pair.A] = pair.Dupl[1]
+	}
+	return dupl
+}
@@ -0,0 +1,38 @@
+package printer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+func TestPrintMatrix(t *testing.T) {
+	fread := func(filename string) ([]byte, error) {
+		return []byte("a\nb\nc\n"), nil
+	}
+	frag := func(filename string, pos, end int) []*syntax.Node {
+		return []*syntax.Node{{Filename: filename, Pos: pos, End: end}}
+	}
+	names := []string{"alice", "bob", "carol"}
+	pairs := []Pair{
+		{A: 0, B: 2, Dupl: [2]float64{0.5, 0.25},
+			Frags: [][2][]*syntax.Node{{frag("alice/a.go", 0, 3), frag("carol/c.go", 2, 5)}}},
+		{A: 0, B: 1},
+		{A: 1, B: 2},
+	}
+	var buf bytes.Buffer
+	if err := PrintMatrix(&buf, fread, names, pairs); err != nil {
+		t.Fatal(err)
+	}
+	expect := "             1   2    3\n" +
+		"  1 alice    -  0%  50%\n" +
+		"    2 bob   0%   -   0%\n" +
+		"  3 carol  25%  0%    -\n" +
+		"\n" +
+		"alice and carol: 50% and 25% duplicated\n" +
+		"  alice/a.go:1,2 and carol/c.go:2,3\n"
+	if buf.String() != expect {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), expect)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strings"
 
 	"github.com/mibk/dupl/syntax"
 )
@@ -19,18 +20,52 @@ func NewPlumbing(w io.Writer, fread ReadFile) Printer {
 
 func (p *plumbing) PrintHeader() error { return nil }
 
-func (p *plumbing) PrintClones(dups [][]*syntax.Node) error {
-	clones, err := prepareClonesInfo(p.ReadFile, dups)
+func (p *plumbing) PrintClones(m syntax.Match) error {
+	clones, err := prepareClonesInfo(p.ReadFile, m.Frags)
 	if err != nil {
 		return err
 	}
+	setEnclosing(clones, m)
 	sort.Sort(byNameAndLine(clones))
-	for i, cl := range clones {
-		nextCl := clones[(i+1)%len(clones)]
-		fmt.Fprintf(p.w, "%s:%d-%d: duplicate of %s:%d-%d\n", cl.filename, cl.lineStart, cl.lineEnd,
-			nextCl.filename, nextCl.lineStart, nextCl.lineEnd)
+	if m.Period > 0 {
+		first, last := clones[0], clones[len(clones)-1]
+		fmt.Fprintf(p.w, "%s:%d-%d: %s\n", first.filename, first.lineStart, last.lineEnd, describe(m))
+	} else if m.Literal != "" || m.Suspect != "" {
+		for _, cl := range clones {
+			fmt.Fprintf(p.w, "%s:%d-%d:%s %s\n", cl.filename, cl.lineStart, cl.lineEnd, cl.inLabel(), describe(m))
+		}
+	} else {
+		for i, cl := range clones {
+			nextCl := clones[(i+1)%len(clones)]
+			fmt.Fprintf(p.w, "%s:%d-%d:%s duplicate of %s:%d-%d%s%s\n", cl.filename, cl.lineStart, cl.lineEnd,
+				cl.inLabel(), nextCl.filename, nextCl.lineStart, nextCl.lineEnd, nextCl.label(), pairSimilarity(m, cl, nextCl))
+		}
+	}
+	for _, warn := range m.Warnings {
+		fmt.Fprintln(p.w, warn)
+	}
+	for _, sugg := range m.Suggestions {
+		cl := clones[0]
+		// the continuation lines of multiline suggestions are indented
+		sugg = strings.ReplaceAll(sugg, "\n", "\n\t")
+		fmt.Fprintf(p.w, "%s:%d-%d: suggestion: %s\n", cl.filename, cl.lineStart, cl.lineEnd, sugg)
+	}
+	for _, nested := range m.Nested {
+		if err := p.PrintClones(nested); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (p *plumbing) PrintFooter() error { return nil }
+func (p *plumbing) PrintFooter(Summary) error { return nil }
+
+// inLabel returns the label of the declaration enclosing the clone
+// preceded by a space and followed by a colon, or an empty string
+// if it is unknown.
+func (cl clone) inLabel() string {
+	if cl.enclosing == "" {
+		return ""
+	}
+	return " " + cl.enclosing + ":"
+}
@@ -6,6 +6,12 @@ type ReadFile func(filename string) ([]byte, error)
 
 type Printer interface {
 	PrintHeader() error
-	PrintClones(dups [][]*syntax.Node) error
-	PrintFooter() error
+	PrintClones(m syntax.Match) error
+	PrintFooter(s Summary) error
+}
+
+// Summary summarizes the search for clones.
+type Summary struct {
+	// Suppressed is the number of tokens excluded from the search.
+	Suppressed int
 }
@@ -4,13 +4,19 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strings"
 
 	"github.com/mibk/dupl/syntax"
 )
 
 type text struct {
-	cnt int
-	w   io.Writer
+	cnt  int
+	reps int
+	lits int
+	decl int
+	sus  int
+	meth int
+	w    io.Writer
 	ReadFile
 }
 
@@ -20,22 +26,81 @@ func NewText(w io.Writer, fread ReadFile) Printer {
 
 func (p *text) PrintHeader() error { return nil }
 
-func (p *text) PrintClones(dups [][]*syntax.Node) error {
-	p.cnt++
-	fmt.Fprintf(p.w, "found %d clones:\n", len(dups))
-	clones, err := prepareClonesInfo(p.ReadFile, dups)
+func (p *text) PrintClones(m syntax.Match) error {
+	return p.printClones(m, "")
+}
+
+// printClones prints the clones and the clones nested in them, which
+// are indented more.
+func (p *text) printClones(m syntax.Match, indent string) error {
+	if m.Period > 0 {
+		p.reps++
+	} else if m.Literal != "" {
+		p.lits++
+	} else if m.Decl != "" {
+		p.decl++
+	} else if m.Suspect != "" {
+		p.sus++
+	} else if m.Method != "" {
+		p.meth++
+	} else {
+		p.cnt++
+	}
+	fmt.Fprintf(p.w, "%sfound %s%s:\n", indent, describe(m), similarity(m))
+	clones, err := prepareClonesInfo(p.ReadFile, m.Frags)
 	if err != nil {
 		return err
 	}
+	setEnclosing(clones, m)
 	sort.Sort(byNameAndLine(clones))
 	for _, cl := range clones {
-		fmt.Fprintf(p.w, "  %s:%d,%d\n", cl.filename, cl.lineStart, cl.lineEnd)
+		fmt.Fprintf(p.w, "%s  %s:%d,%d%s\n", indent, cl.filename, cl.lineStart, cl.lineEnd, cl.label())
+	}
+	if m.PairSimilarity != nil {
+		for i, cl := range clones {
+			for _, cl2 := range clones[i+1:] {
+				fmt.Fprintf(p.w, "%s  %s:%d,%d and %s:%d,%d: text similarity %d%%\n", indent,
+					cl.filename, cl.lineStart, cl.lineEnd, cl2.filename, cl2.lineStart, cl2.lineEnd,
+					percent(m.PairSimilarity[cl.index][cl2.index]))
+			}
+		}
+	}
+	for _, warn := range m.Warnings {
+		fmt.Fprintf(p.w, "%s  warning: %s\n", indent, warn)
+	}
+	for _, sugg := range m.Suggestions {
+		sugg = strings.ReplaceAll(sugg, "\n", "\n"+indent+"    ")
+		fmt.Fprintf(p.w, "%s  suggestion: %s\n", indent, sugg)
+	}
+	for _, nested := range m.Nested {
+		if err := p.printClones(nested, indent+"  "); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (p *text) PrintFooter() error {
-	_, err := fmt.Fprintf(p.w, "\nFound total %d clone groups.\n", p.cnt)
+func (p *text) PrintFooter(s Summary) error {
+	var reps string
+	if p.reps > 0 {
+		reps = fmt.Sprintf(" and %d repetitions", p.reps)
+	}
+	if p.lits > 0 {
+		reps += fmt.Sprintf(" and %d repeated literals", p.lits)
+	}
+	if p.decl > 0 {
+		reps += fmt.Sprintf(" and %d duplicated type declarations", p.decl)
+	}
+	if p.sus > 0 {
+		reps += fmt.Sprintf(" and %d suspicious constructs", p.sus)
+	}
+	if p.meth > 0 {
+		reps += fmt.Sprintf(" and %d cloned methods", p.meth)
+	}
+	_, err := fmt.Fprintf(p.w, "\nFound total %d clone groups%s.\n", p.cnt, reps)
+	if err == nil && s.Suppressed > 0 {
+		_, err = fmt.Fprintf(p.w, "Suppressed %d tokens of boilerplate code.\n", s.Suppressed)
+	}
 	return err
 }
 
@@ -46,21 +111,77 @@ func prepareClonesInfo(fread ReadFile, dups [][]*syntax.Node) ([]clone, error) {
 		if cnt == 0 {
 			panic("zero length dup")
 		}
-		nstart := dup[0]
-		nend := dup[cnt-1]
-
-		file, err := fread(nstart.Filename)
+		file, err := fread(dup[0].Filename)
 		if err != nil {
 			return nil, err
 		}
 
-		cl := clone{filename: nstart.Filename}
-		cl.lineStart, cl.lineEnd = blockLines(file, nstart.Pos, nend.End)
+		cl := clone{filename: dup[0].Filename, index: i}
+		pos, end := syntax.Span(dup)
+		cl.lineStart, cl.lineEnd = blockLines(file, pos, end)
 		clones[i] = cl
 	}
 	return clones, nil
 }
 
+// describe describes the number of clones in m or, if m is a repetition,
+// the number of repetitions and the size of the repeated pattern or,
+// if m is a repeated literal or type declaration, the number of its
+// occurrences or, if m is a suspicious construct, its description or,
+// if m is a cloned method, the number of its implementations.
+func describe(m syntax.Match) string {
+	if m.Period > 0 {
+		return fmt.Sprintf("%d repetitions of %d syntax units", len(m.Frags), m.Period)
+	}
+	if m.Literal != "" {
+		return fmt.Sprintf("%d occurrences of %s", len(m.Frags), m.Literal)
+	}
+	if m.Decl != "" {
+		return fmt.Sprintf("%d declarations of %s", len(m.Frags), m.Decl)
+	}
+	if m.Suspect != "" {
+		return fmt.Sprintf("%d %s", len(m.Frags), m.Suspect)
+	}
+	if m.Method != "" {
+		return fmt.Sprintf("%d implementations of method %s", len(m.Frags), m.Method)
+	}
+	return fmt.Sprintf("%d clones", len(m.Frags))
+}
+
+// similarity describes the similarity of the clones in m. It returns
+// an empty string for exact clones whose textual similarity is unknown.
+func similarity(m syntax.Match) string {
+	return describeSimilarity(m, m.TextSimilarity)
+}
+
+// pairSimilarity describes the similarity of the clones cl1 and cl2 in m.
+func pairSimilarity(m syntax.Match, cl1, cl2 clone) string {
+	if m.PairSimilarity == nil {
+		return describeSimilarity(m, 0)
+	}
+	return describeSimilarity(m, m.PairSimilarity[cl1.index][cl2.index])
+}
+
+func describeSimilarity(m syntax.Match, textSimilarity float64) string {
+	var desc []string
+	if m.Similarity < 1 {
+		desc = append(desc, fmt.Sprintf("%d%% similar", percent(m.Similarity)))
+	}
+	if m.PairSimilarity != nil {
+		desc = append(desc, fmt.Sprintf("text similarity %d%%", percent(textSimilarity)))
+	}
+	if len(desc) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(desc, ", ") + ")"
+}
+
+// percent converts the ratio to whole percents. The ratios are rounded down
+// so that only identical clones are reported as 100 % similar.
+func percent(ratio float64) int {
+	return int(ratio * 100)
+}
+
 func blockLines(file []byte, from, to int) (int, int) {
 	line := 1
 	lineStart, lineEnd := 0, 0
@@ -80,12 +201,34 @@ func blockLines(file []byte, from, to int) (int, int) {
 }
 
 type clone struct {
+	index     int // index of the fragment in the match
 	filename  string
 	lineStart int
 	lineEnd   int
+	enclosing string // label of the enclosing declaration
 	fragment  []byte
 }
 
+// label returns the label of the declaration enclosing the clone
+// preceded by a space, or an empty string if it is unknown.
+func (cl clone) label() string {
+	if cl.enclosing == "" {
+		return ""
+	}
+	return " " + cl.enclosing
+}
+
+// setEnclosing sets the labels of the declarations enclosing the clones
+// of m, if they are known.
+func setEnclosing(clones []clone, m syntax.Match) {
+	if len(m.Enclosing) != len(m.Frags) {
+		return
+	}
+	for i := range clones {
+		clones[i].enclosing = m.Enclosing[clones[i].index]
+	}
+}
+
 type byNameAndLine []clone
 
 func (c byNameAndLine) Len() int { return len(c) }
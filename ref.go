@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/mibk/dupl/index"
+	"github.com/mibk/dupl/job"
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// refCorpus is the corpus of the files in the reference index.
+const refCorpus = -1
+
+// refSources maps the files in the reference index to their sources.
+var refSources = make(map[string][]byte)
+
+// readFile reads the file from the reference index or from the disk.
+func readFile(filename string) ([]byte, error) {
+	if src, ok := refSources[filename]; ok {
+		return src, nil
+	}
+	return ioutil.ReadFile(filename)
+}
+
+// buildIndex writes the index of the files to the file given by -o.
+func buildIndex(cfg *golang.Config) error {
+	if cfg.TypeCheck {
+		return errors.New("type-checked files cannot be indexed")
+	}
+	suppress, err := suppressSettings()
+	if err != nil {
+		return err
+	}
+	idx := &index.Index{Level: cfg.Level, Normalize: cfg.Normalize, Suppress: suppress}
+	for seq := range job.Parse(filesFeed(cfg), cfg) {
+		filename := seq[0].Filename
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		idx.Add(filename, src, seq)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := idx.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readIndex reads the reference index given by -ref.
+func readIndex(cfg *golang.Config) (*index.Index, error) {
+	f, err := os.Open(*ref)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	idx, err := index.Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", *ref, err)
+	}
+	if cfg.TypeCheck {
+		return nil, fmt.Errorf("%s: reference index cannot be used with -types", *ref)
+	}
+	if idx.Level != cfg.Level || idx.Normalize != cfg.Normalize {
+		return nil, fmt.Errorf("%s: index built with different -level or -normalize", *ref)
+	}
+	suppress, err := suppressSettings()
+	if err != nil {
+		return nil, err
+	}
+	if idx.Suppress != suppress {
+		return nil, fmt.Errorf("%s: index built with different -suppress or -suppress-file", *ref)
+	}
+	return idx, nil
+}
+
+// suppressSettings describes the suppressors given by the -suppress
+// and -suppress-file flags.
+func suppressSettings() (string, error) {
+	settings := *suppress
+	if *suppressFile != "" {
+		src, err := ioutil.ReadFile(*suppressFile)
+		if err != nil {
+			return "", err
+		}
+		settings += "\n" + string(src)
+	}
+	return settings, nil
+}
+
+// refName returns the name of the file in the reference index, which
+// differs from the names of the searched files.
+func refName(filename string) string {
+	return *ref + ":" + filename
+}
+
+// withReference returns a channel of the files in the reference index
+// followed by the files from schan.
+func withReference(schan chan []*syntax.Node, idx *index.Index) chan []*syntax.Node {
+	for _, f := range idx.Files {
+		refSources[refName(f.Filename)] = f.Src
+	}
+	rchan := make(chan []*syntax.Node)
+	go func() {
+		for i := range idx.Files {
+			seq := idx.Files[i].Seq()
+			for _, n := range seq {
+				n.Filename, n.Corpus = refName(n.Filename), refCorpus
+			}
+			rchan <- seq
+		}
+		for seq := range schan {
+			rchan <- seq
+		}
+		close(rchan)
+	}()
+	return rchan
+}
+
+// matchesRef reports whether some of the fragments are in the reference
+// index and some are not.
+func matchesRef(frags [][]*syntax.Node) bool {
+	var inRef, inCode bool
+	for _, frag := range frags {
+		if frag[0].Corpus == refCorpus {
+			inRef = true
+		} else {
+			inCode = true
+		}
+	}
+	return inRef && inCode
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mibk/dupl/index"
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+func TestReadIndexSettings(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "ref.idx")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := &index.Index{Level: golang.Operators, Suppress: "errchecks"}
+	if err := idx.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	defer func(r, s string) { *ref, *suppress = r, s }(*ref, *suppress)
+	*ref = filename
+	testCases := []struct {
+		level    int
+		suppress string
+		ok       bool
+	}{
+		{golang.Operators, "errchecks", true},
+		{golang.Kinds, "errchecks", false},
+		{golang.Operators, "", false},
+		{golang.Operators, "errchecks,maplits", false},
+	}
+	for _, tc := range testCases {
+		*suppress = tc.suppress
+		_, err := readIndex(&golang.Config{Level: tc.level})
+		if (err == nil) != tc.ok {
+			t.Errorf("-level %d -suppress %q: got error %v", tc.level, tc.suppress, err)
+		}
+	}
+}
+
+func TestWithReference(t *testing.T) {
+	defer func(r string) { *ref = r }(*ref)
+	*ref = "ref.idx"
+	idx := new(index.Index)
+	idx.Add("a.go", []byte("package a"), []*syntax.Node{{Filename: "a.go"}})
+	schan := make(chan []*syntax.Node, 1)
+	schan <- []*syntax.Node{{Filename: "a.go"}}
+	close(schan)
+
+	var names []string
+	for seq := range withReference(schan, idx) {
+		names = append(names, seq[0].Filename)
+	}
+	if len(names) != 2 || names[0] != "ref.idx:a.go" || names[1] != "a.go" {
+		t.Errorf("got files %v, want ref.idx:a.go and a.go", names)
+	}
+	if src, err := readFile("ref.idx:a.go"); err != nil || string(src) != "package a" {
+		t.Errorf("got source %q of the reference file, error %v", src, err)
+	}
+}
+
+func TestSearchesSkipReference(t *testing.T) {
+	files := writeFiles(t, map[string]string{
+		"a.go": "package a\n\nfunc f(x int) bool {\n\treturn x > 0 && x > 0\n}\n",
+	})
+	data := []*syntax.Node{{Filename: files["a.go"], Corpus: refCorpus}}
+	if found, err := findSuspects(data); err != nil || len(found) > 0 {
+		t.Errorf("findSuspects: got %d suspects, error %v", len(found), err)
+	}
+	if found, err := findLiterals(data); err != nil || len(found) > 0 {
+		t.Errorf("findLiterals: got %d literals, error %v", len(found), err)
+	}
+	if found, err := findDecls(data, new(golang.Config)); err != nil || len(found) > 0 {
+		t.Errorf("findDecls: got %d declarations, error %v", len(found), err)
+	}
+
+	data[0].Corpus = 0
+	if found, err := findSuspects(data); err != nil || len(found) != 1 {
+		t.Errorf("findSuspects: got %d suspects, error %v, want 1", len(found), err)
+	}
+}
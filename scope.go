@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// Scopes of clone groups selected by the -scope flag.
+var scopes = map[string]bool{
+	"file":     true,
+	"files":    true,
+	"packages": true,
+	"modules":  true,
+}
+
+// satisfiesScope reports whether the fragments are in the scope given
+// by the -scope flag: all in the same file (file), or spanning at least
+// two files (files), packages (packages) or modules (modules).
+func satisfiesScope(frags [][]*syntax.Node, cfg *golang.Config, mods moduleCache) bool {
+	seen := make(map[string]bool)
+	for _, frag := range frags {
+		filename := frag[0].Filename
+		dir := filepath.Dir(filename)
+		switch *scope {
+		case "file", "files":
+			seen[filename] = true
+		case "packages":
+			// the external test package is different from the package
+			seen[dir+" "+cfg.Package(filename)] = true
+		case "modules":
+			seen[mods.root(dir)] = true
+		}
+	}
+	if *scope == "file" {
+		return len(seen) == 1
+	}
+	return len(seen) > 1
+}
+
+// moduleCache caches the root directories of modules by the directories
+// inside them.
+type moduleCache map[string]string
+
+// root returns the directory of the nearest go.mod file containing the
+// directory dir, or an empty string if there is none.
+func (c moduleCache) root(dir string) string {
+	if root, ok := c[dir]; ok {
+		return root
+	}
+	var root string
+	if abs, err := filepath.Abs(dir); err == nil {
+		if _, err := os.Stat(filepath.Join(abs, "go.mod")); err == nil {
+			root = abs
+		} else if parent := filepath.Dir(abs); parent != abs {
+			root = c.root(parent)
+		}
+	}
+	c[dir] = root
+	return root
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+func TestSatisfiesScope(t *testing.T) {
+	files := writeFiles(t, map[string]string{
+		"go.mod":             "module m\n",
+		"a/a.go":             "package a\n",
+		"a/b.go":             "package a\n",
+		"a/a_test.go":        "package a_test\n",
+		"b/b.go":             "package b\n",
+		"nested/go.mod":      "module m/nested\n",
+		"nested/n/n.go":      "package n\n",
+		"nested/n/n_test.go": "package n\n",
+	})
+	cfg := new(golang.Config)
+	for name, filename := range files {
+		if filepath.Ext(name) == ".go" {
+			if _, err := cfg.Parse(filename); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	defer func(old string) { *scope = old }(*scope)
+	testCases := []struct {
+		scope string
+		files []string
+		ok    bool
+	}{
+		{"file", []string{"a/a.go", "a/a.go"}, true},
+		{"file", []string{"a/a.go", "a/b.go"}, false},
+		{"files", []string{"a/a.go", "a/a.go"}, false},
+		{"files", []string{"a/a.go", "a/b.go"}, true},
+		{"packages", []string{"a/a.go", "a/b.go"}, false},
+		{"packages", []string{"a/a.go", "a/a_test.go"}, true}, // external test package
+		{"packages", []string{"nested/n/n.go", "nested/n/n_test.go"}, false},
+		{"packages", []string{"a/a.go", "b/b.go"}, true},
+		{"modules", []string{"a/a.go", "b/b.go"}, false},
+		{"modules", []string{"a/a.go", "nested/n/n.go"}, true},
+		{"modules", []string{"a/a.go", "b/b.go", "nested/n/n.go"}, true},
+	}
+	for _, tc := range testCases {
+		*scope = tc.scope
+		var frags [][]*syntax.Node
+		for _, name := range tc.files {
+			frags = append(frags, []*syntax.Node{{Filename: files[name]}})
+		}
+		if ok := satisfiesScope(frags, cfg, make(moduleCache)); ok != tc.ok {
+			t.Errorf("-scope %s %v: got %v, want %v", tc.scope, tc.files, ok, tc.ok)
+		}
+	}
+}
+
+func TestModuleRoot(t *testing.T) {
+	files := writeFiles(t, map[string]string{
+		"go.mod":        "module m\n",
+		"a/b/c.go":      "package b\n",
+		"nested/go.mod": "module m/nested\n",
+		"nested/n/n.go": "package n\n",
+	})
+	root := filepath.Dir(files["go.mod"])
+	mods := make(moduleCache)
+	testCases := []struct {
+		dir  string
+		root string
+	}{
+		{filepath.Dir(files["a/b/c.go"]), root},
+		{root, root},
+		{filepath.Dir(files["nested/n/n.go"]), filepath.Dir(files["nested/go.mod"])},
+	}
+	for _, tc := range testCases {
+		if got := mods.root(tc.dir); got != tc.root {
+			t.Errorf("root of %s: got %q, want %q", tc.dir, got, tc.root)
+		}
+	}
+}
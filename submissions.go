@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mibk/dupl/printer"
+	"github.com/mibk/dupl/syntax"
+)
+
+// topFragPairs is the number of the largest pairs of duplicated
+// fragments reported for each pair of submissions.
+const topFragPairs = 5
+
+// listSubmissions returns the top-level directories in the paths.
+func listSubmissions(paths []string) ([]string, error) {
+	var subs []string
+	for _, path := range paths {
+		infos, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if info.IsDir() && !strings.HasPrefix(info.Name(), ".") {
+				subs = append(subs, filepath.Join(path, info.Name()))
+			}
+		}
+	}
+	return subs, nil
+}
+
+// submissionPairs computes the duplication between each pair of the n
+// submissions from the clones in dupls. The pairs are ranked by the
+// higher of their ratios of duplicated tokens. The files in the reference
+// index are not part of any submission.
+func submissionPairs(dupls []syntax.Match, data []*syntax.Node, n int) []printer.Pair {
+	total := make([]int, n)
+	for _, node := range data {
+		if node.Filename != "" && node.Corpus != refCorpus {
+			total[node.Corpus]++
+		}
+	}
+
+	// covered[[2]int{a, b}] are the tokens of a duplicated in b
+	covered := make(map[[2]int]map[*syntax.Node]bool)
+	frags := make(map[[2]int][][2][]*syntax.Node)
+	for _, dupl := range dupls {
+		for i, x := range dupl.Frags {
+			for _, y := range dupl.Frags[i+1:] {
+				fa, fb := x, y
+				a, b := fa[0].Corpus, fb[0].Corpus
+				if a == b || a == refCorpus || b == refCorpus {
+					continue
+				}
+				if a > b {
+					a, b, fa, fb = b, a, fb, fa
+				}
+				cover(covered, [2]int{a, b}, fa)
+				cover(covered, [2]int{b, a}, fb)
+				frags[[2]int{a, b}] = append(frags[[2]int{a, b}], [2][]*syntax.Node{fa, fb})
+			}
+		}
+	}
+
+	var pairs []printer.Pair
+	for a := 0; a < n; a++ {
+		for b := a + 1; b < n; b++ {
+			pair := printer.Pair{A: a, B: b, Frags: frags[[2]int{a, b}]}
+			if total[a] > 0 && total[b] > 0 {
+				pair.Dupl[0] = float64(len(covered[[2]int{a, b}])) / float64(total[a])
+				pair.Dupl[1] = float64(len(covered[[2]int{b, a}])) / float64(total[b])
+			}
+			sort.SliceStable(pair.Frags, func(i, j int) bool {
+				return fragSize(pair.Frags[i][0]) > fragSize(pair.Frags[j][0])
+			})
+			if len(pair.Frags) > topFragPairs {
+				pair.Frags = pair.Frags[:topFragPairs]
+			}
+			pairs = append(pairs, pair)
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return maxDupl(pairs[i]) > maxDupl(pairs[j])
+	})
+	return pairs
+}
+
+// cover adds the tokens of the fragment to the set of covered tokens
+// of the pair of submissions.
+func cover(covered map[[2]int]map[*syntax.Node]bool, pair [2]int, frag []*syntax.Node) {
+	set, ok := covered[pair]
+	if !ok {
+		set = make(map[*syntax.Node]bool)
+		covered[pair] = set
+	}
+	var walk func(n *syntax.Node)
+	walk = func(n *syntax.Node) {
+		set[n] = true
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	for _, n := range frag {
+		walk(n)
+	}
+}
+
+func fragSize(frag []*syntax.Node) int {
+	var size int
+	for _, n := range frag {
+		size += n.Owns + 1
+	}
+	return size
+}
+
+func maxDupl(pair printer.Pair) float64 {
+	if pair.Dupl[0] > pair.Dupl[1] {
+		return pair.Dupl[0]
+	}
+	return pair.Dupl[1]
+}
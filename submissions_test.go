@@ -0,0 +1,74 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+func TestListSubmissions(t *testing.T) {
+	files := writeFiles(t, map[string]string{
+		"alice/a.go": "package a\n",
+		"bob/b.go":   "package b\n",
+		".git/HEAD":  "",
+		"README":     "",
+	})
+	dir := filepath.Dir(files["README"])
+	subs, err := listSubmissions([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 2 || subs[0] != filepath.Join(dir, "alice") || subs[1] != filepath.Join(dir, "bob") {
+		t.Errorf("got %v, want alice and bob", subs)
+	}
+}
+
+func TestSubmissionPairs(t *testing.T) {
+	// submission 0 has 4 tokens, 1 has 2 and 2 has 2, the last tokens
+	// are in the reference index
+	var data []*syntax.Node
+	for i, corpus := range []int{0, 0, 0, 0, 1, 1, 2, 2, refCorpus, refCorpus} {
+		data = append(data, &syntax.Node{Filename: "f", Pos: i, Corpus: corpus})
+	}
+	data[0].AddChildren(data[1])
+	data = append(data, &syntax.Node{Type: -2}) // the end of a file is no token
+
+	dupls := []syntax.Match{
+		// the first two tokens of 0 are duplicated in the first of 1
+		// and within 2 itself and in the reference index
+		{Frags: [][]*syntax.Node{data[0:1], data[4:5], data[6:7], data[7:8], data[8:9]}},
+	}
+	pairs := submissionPairs(dupls, data, 3)
+	if len(pairs) != 3 {
+		t.Fatalf("got %d pairs, want 3", len(pairs))
+	}
+	testCases := []struct {
+		a, b  int
+		dupl  [2]float64
+		frags int
+	}{
+		{0, 1, [2]float64{0.5, 0.5}, 1},
+		{0, 2, [2]float64{0.5, 1}, 2},
+		{1, 2, [2]float64{0.5, 1}, 2},
+	}
+	for _, tc := range testCases {
+		var found bool
+		for _, p := range pairs {
+			if p.A != tc.a || p.B != tc.b {
+				continue
+			}
+			found = true
+			if p.Dupl != tc.dupl || len(p.Frags) != tc.frags {
+				t.Errorf("%d and %d: got %v with %d fragments, want %v with %d", tc.a, tc.b,
+					p.Dupl, len(p.Frags), tc.dupl, tc.frags)
+			}
+		}
+		if !found {
+			t.Errorf("%d and %d: pair not found", tc.a, tc.b)
+		}
+	}
+	if pairs[2].A != 0 || pairs[2].B != 1 {
+		t.Errorf("the least duplicated pair is %d and %d, want 0 and 1", pairs[2].A, pairs[2].B)
+	}
+}
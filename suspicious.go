@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mibk/dupl/syntax"
+	"github.com/mibk/dupl/syntax/golang"
+)
+
+// findSuspects returns the identical parts of single constructs, which
+// are likely copy-paste bugs, see golang.Suspects.
+func findSuspects(data []*syntax.Node) ([]syntax.Match, error) {
+	var dupls []syntax.Match
+	seen := make(map[string]bool)
+	for _, n := range data {
+		// the files in the reference index are not searched
+		if n.Filename == "" || n.Corpus == refCorpus || seen[n.Filename] {
+			continue
+		}
+		seen[n.Filename] = true
+		file, err := readFile(n.Filename)
+		if err != nil {
+			return nil, err
+		}
+		suspects, err := golang.Suspects(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range suspects {
+			m := syntax.Match{
+				Hash:       fmt.Sprintf("suspect %s %d", n.Filename, s.Frags[0][0]),
+				Similarity: 1,
+				Suspect:    s.Desc,
+			}
+			for _, frag := range s.Frags {
+				m.Frags = append(m.Frags, []*syntax.Node{{Filename: n.Filename, Pos: frag[0], End: frag[1]}})
+			}
+			dupls = append(dupls, m)
+		}
+	}
+	return dupls, nil
+}
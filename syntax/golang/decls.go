@@ -0,0 +1,72 @@
+package golang
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// A TypeDecl is a declaration of a struct or interface type.
+type TypeDecl struct {
+	Name      string
+	Pos, End  int // offsets of the type specification
+	Interface bool
+
+	// Members are the fields of the struct with their types and tags or
+	// the methods of the interface with their signatures, sorted.
+	// Embedded fields and interfaces are represented by their types.
+	Members []string
+}
+
+// TypeDecls returns the struct and interface types declared in the source.
+func TypeDecls(src []byte) ([]TypeDecl, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	var decls []TypeDecl
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		decl := TypeDecl{
+			Name: spec.Name.Name,
+			Pos:  fset.Position(spec.Pos()).Offset,
+			End:  fset.Position(spec.End()).Offset,
+		}
+		var fields *ast.FieldList
+		switch t := spec.Type.(type) {
+		case *ast.StructType:
+			fields = t.Fields
+		case *ast.InterfaceType:
+			fields, decl.Interface = t.Methods, true
+		default:
+			return true
+		}
+		for _, field := range fields.List {
+			typ := types.ExprString(field.Type)
+			if _, ok := field.Type.(*ast.FuncType); ok && decl.Interface {
+				typ = typ[len("func"):]
+			} else if len(field.Names) > 0 {
+				typ = " " + typ
+			}
+			if field.Tag != nil {
+				typ += " " + field.Tag.Value
+			}
+			if len(field.Names) == 0 {
+				decl.Members = append(decl.Members, typ)
+			}
+			for _, name := range field.Names {
+				decl.Members = append(decl.Members, name.Name+typ)
+			}
+		}
+		sort.Strings(decl.Members)
+		decls = append(decls, decl)
+		return true
+	})
+	return decls, nil
+}
@@ -0,0 +1,36 @@
+package golang
+
+import (
+	"reflect"
+	"testing"
+)
+
+const declsSrc = "package p\n" +
+	"\n" +
+	"type User struct {\n" +
+	"	ID, Age int\n" +
+	"	Name    string `json:\"name\"`\n" +
+	"	*Base\n" +
+	"	handle  func(x int) error\n" +
+	"}\n" +
+	"\n" +
+	"type Store interface {\n" +
+	"	io.Closer\n" +
+	"	Get(id int) (*User, error)\n" +
+	"}\n" +
+	"\n" +
+	"type ID int\n"
+
+func TestTypeDecls(t *testing.T) {
+	decls, err := TypeDecls([]byte(declsSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []TypeDecl{
+		{"User", 16, 108, false, []string{"*Base", "Age int", "ID int", "Name string `json:\"name\"`", "handle func(x int) error"}},
+		{"Store", 115, 173, true, []string{"Get(id int) (*User, error)", "io.Closer"}},
+	}
+	if !reflect.DeepEqual(decls, expect) {
+		t.Errorf("got %v, want %v", decls, expect)
+	}
+}
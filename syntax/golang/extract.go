@@ -0,0 +1,668 @@
+package golang
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// An Extraction is a function that could replace the clones.
+type Extraction struct {
+	// Func is the signature of the function.
+	Func string
+
+	// Decl is the declaration of the function.
+	Decl string
+
+	// Calls are the statements calling the function that replace
+	// each of the clones.
+	Calls []string
+}
+
+// SuggestExtract suggests a function that could replace the statements of
+// the fragments. The identifiers and literals of the fragments are aligned
+// and those that consistently differ become parameters of the function.
+// The variables declared outside of the fragments become parameters as
+// well, and those written in the fragments become results along with
+// the variables declared in the fragments and used after them. The files
+// of the fragments are type-checked.
+func (c *Config) SuggestExtract(frags [][]*syntax.Node) (Extraction, bool) {
+	x, ok := c.align(frags, true)
+	if !ok {
+		return Extraction{}, false
+	}
+	return x.suggest("extracted")
+}
+
+// align aligns the fragments. If extractable is set, the control flow
+// must not leave the fragments other than at their ends.
+func (c *Config) align(frags [][]*syntax.Node, extractable bool) (*extraction, bool) {
+	insts := make([]*instance, len(frags))
+	for i, frag := range frags {
+		inst, ok := c.instance(frag)
+		// the items differ when the clones are normalized or suppressed
+		// differently
+		if !ok || extractable && !inst.extractable() || i > 0 && len(inst.items) != len(insts[0].items) {
+			return nil, false
+		}
+		insts[i] = inst
+	}
+
+	x := &extraction{
+		insts:    insts,
+		params:   make(map[string]*extractParam),
+		replaced: make(map[int]*extractParam),
+	}
+	for i := range insts[0].items {
+		if !x.align(i) {
+			return nil, false
+		}
+	}
+	return x, true
+}
+
+// checkedPkg is a type-checked package.
+type checkedPkg struct {
+	files   map[string]*ast.File
+	srcs    map[string][]byte
+	info    *types.Info
+	pkg     *types.Package
+	err     error // the first type error
+	lastUse map[types.Object]token.Pos
+}
+
+// checkPackage type-checks the package of the file. The packages are
+// cached.
+func (c *Config) checkPackage(filename string) (*checkedPkg, *ast.File) {
+	c.types.once.Do(c.initTypes)
+	fset := c.types.fset
+
+	c.types.mu.Lock()
+	defer c.types.mu.Unlock()
+	if c.types.checked == nil {
+		c.types.checked = make(map[string]*checkedPkg)
+	}
+	for _, p := range c.types.checked {
+		if f, ok := p.files[filename]; ok {
+			return p, f
+		}
+	}
+
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil
+	}
+	target, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil, nil
+	}
+	name := target.Name.Name
+	key := filepath.Dir(filename) + " " + name
+	if p, ok := c.types.checked[key]; ok {
+		// the file is not in the package, e.g. a different path to it
+		return p, nil
+	}
+
+	p := &checkedPkg{
+		files: map[string]*ast.File{filename: target},
+		srcs:  map[string][]byte{filename: src},
+	}
+	files := []*ast.File{target}
+	others, _ := filepath.Glob(filepath.Join(filepath.Dir(filename), "*.go"))
+	for _, other := range others {
+		if other == filename {
+			continue
+		}
+		src, err := ioutil.ReadFile(other)
+		if err != nil {
+			continue
+		}
+		if f, err := parser.ParseFile(fset, other, src, 0); err == nil && f.Name.Name == name {
+			p.files[other] = f
+			p.srcs[other] = src
+			files = append(files, f)
+		}
+	}
+	p.info = &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{
+		Importer: c.types.importer,
+		Error:    func(error) {}, // use whatever could be resolved
+	}
+	p.pkg, p.err = conf.Check(pkgPath(filepath.Dir(filename), name), fset, files, p.info)
+	p.lastUse = make(map[types.Object]token.Pos)
+	for id, obj := range p.info.Uses {
+		if id.Pos() > p.lastUse[obj] {
+			p.lastUse[obj] = id.Pos()
+		}
+	}
+	c.types.checked[key] = p
+	return p, target
+}
+
+// An instance is one of the fragments to be extracted.
+type instance struct {
+	*checkedPkg
+	tf         *token.File
+	stmts      []ast.Stmt
+	start, end token.Pos
+	items      []item
+	written    map[types.Object]bool
+}
+
+// An item is an aligned node of the fragment.
+type item struct {
+	kind string
+	text string // identifier, literal or operator
+	node ast.Node
+}
+
+// instance finds the statements of the fragment.
+func (c *Config) instance(frag []*syntax.Node) (*instance, bool) {
+	p, file := c.checkPackage(frag[0].Filename)
+	if file == nil {
+		return nil, false
+	}
+	tf := c.types.fset.File(file.Pos())
+	var stmts []ast.Stmt
+	ast.Inspect(file, func(n ast.Node) bool {
+		var list []ast.Stmt
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			list = n.List
+		case *ast.CaseClause:
+			list = n.Body
+		case *ast.CommClause:
+			list = n.Body
+		}
+		for i, stmt := range list {
+			if tf.Offset(stmt.Pos()) != frag[0].Pos || i+len(frag) > len(list) {
+				continue
+			}
+			for j, n := range frag {
+				s := list[i+j]
+				if tf.Offset(s.Pos()) != n.Pos || tf.Offset(s.End()) != n.End {
+					return true
+				}
+			}
+			stmts = list[i : i+len(frag)]
+		}
+		return stmts == nil
+	})
+	if stmts == nil {
+		return nil, false
+	}
+
+	inst := &instance{
+		checkedPkg: p,
+		tf:         tf,
+		stmts:      stmts,
+		start:      stmts[0].Pos(),
+		end:        stmts[len(stmts)-1].End(),
+		written:    make(map[types.Object]bool),
+	}
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if n == nil {
+				return false
+			}
+			it := item{kind: fmt.Sprintf("%T", n), node: n}
+			switch n := n.(type) {
+			case *ast.Ident:
+				it.text = n.Name
+			case *ast.BasicLit:
+				it.text = n.Value
+			case *ast.BinaryExpr:
+				it.text = n.Op.String()
+			case *ast.UnaryExpr:
+				it.text = n.Op.String()
+				if n.Op == token.AND {
+					inst.write(n.X)
+				}
+			case *ast.AssignStmt:
+				it.text = n.Tok.String()
+				for _, x := range n.Lhs {
+					inst.write(x)
+				}
+			case *ast.IncDecStmt:
+				it.text = n.Tok.String()
+				inst.write(n.X)
+			case *ast.RangeStmt:
+				if n.Tok == token.ASSIGN {
+					inst.write(n.Key)
+					inst.write(n.Value)
+				}
+			case *ast.BranchStmt:
+				it.text = n.Tok.String()
+			}
+			inst.items = append(inst.items, it)
+			return true
+		})
+	}
+	return inst, true
+}
+
+// write records that the variable in x is written. Writing through
+// pointers, slices and maps does not count.
+func (inst *instance) write(x ast.Expr) {
+	for {
+		switch e := x.(type) {
+		case *ast.Ident:
+			if obj := inst.info.Uses[e]; obj != nil {
+				inst.written[obj] = true
+			}
+			return
+		case *ast.ParenExpr:
+			x = e.X
+		case *ast.SelectorExpr:
+			if _, ok := inst.info.TypeOf(e.X).(*types.Pointer); ok {
+				return
+			}
+			x = e.X
+		case *ast.IndexExpr:
+			typ := inst.info.TypeOf(e.X)
+			if typ == nil {
+				return
+			}
+			if _, ok := typ.Underlying().(*types.Array); !ok {
+				return
+			}
+			x = e.X
+		default:
+			return
+		}
+	}
+}
+
+// extractable reports whether the control flow of the statements
+// does not leave them other than at their end.
+func (inst *instance) extractable() bool {
+	ok := true
+	var loops, breakables int
+	var stack []ast.Node
+	for _, stmt := range inst.stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if n == nil {
+				switch stack[len(stack)-1].(type) {
+				case *ast.ForStmt, *ast.RangeStmt:
+					loops--
+					breakables--
+				case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+					breakables--
+				}
+				stack = stack[:len(stack)-1]
+				return false
+			}
+			if !ok {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				// returns of function literals are fine
+				return false
+			case *ast.ForStmt, *ast.RangeStmt:
+				loops++
+				breakables++
+			case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+				breakables++
+			case *ast.ReturnStmt, *ast.LabeledStmt, *ast.DeferStmt:
+				ok = false
+			case *ast.BranchStmt:
+				switch {
+				case n.Label != nil:
+					ok = false
+				case n.Tok == token.BREAK:
+					ok = breakables > 0
+				case n.Tok == token.CONTINUE:
+					ok = loops > 0
+				}
+			}
+			if !ok {
+				// the children are not visited, so n is not popped
+				return false
+			}
+			stack = append(stack, n)
+			return true
+		})
+	}
+	return ok
+}
+
+// class returns the class of the identifier: local for the variables
+// declared in the fragment, free for the variables declared outside
+// of it in the enclosing function and global otherwise.
+func (inst *instance) class(id *ast.Ident) (types.Object, string) {
+	obj := inst.info.Uses[id]
+	if obj == nil {
+		obj = inst.info.Defs[id]
+	}
+	v, ok := obj.(*types.Var)
+	if !ok || v.IsField() || v.Parent() == nil || v.Parent() == inst.pkg.Scope() {
+		return obj, "global"
+	}
+	if inst.start <= v.Pos() && v.Pos() < inst.end {
+		return obj, "local"
+	}
+	return obj, "free"
+}
+
+// extraction aligns the instances of the fragments.
+type extraction struct {
+	insts  []*instance
+	vars   []map[types.Object]types.Object // variables of the first instance by those of the others
+	order  []types.Object                  // variables of the first instance
+	locals map[types.Object]bool           // whether the variables are declared in the fragment
+
+	params    map[string]*extractParam // parameters by the differing texts
+	paramList []*extractParam
+	replaced  map[int]*extractParam // parameters by the items they replace
+}
+
+type extractParam struct {
+	texts []string
+	typ   types.Type
+	name  string // name of the parameter, if known
+}
+
+// align aligns the i-th items of the instances.
+func (x *extraction) align(i int) bool {
+	if x.vars == nil {
+		x.vars = make([]map[types.Object]types.Object, len(x.insts))
+		for k := range x.vars {
+			x.vars[k] = make(map[types.Object]types.Object)
+		}
+		x.locals = make(map[types.Object]bool)
+	}
+	first := x.insts[0].items[i]
+	texts := make([]string, len(x.insts))
+	same := true
+	for k, inst := range x.insts {
+		it := inst.items[i]
+		if it.kind != first.kind {
+			return false
+		}
+		texts[k] = it.text
+		same = same && it.text == first.text
+	}
+
+	switch n := first.node.(type) {
+	case *ast.BasicLit:
+		if same {
+			return true
+		}
+		typ := types.Default(x.insts[0].info.TypeOf(n))
+		for _, inst := range x.insts[1:] {
+			if t := types.Default(inst.info.TypeOf(inst.items[i].node.(ast.Expr))); t == nil || !types.Identical(t, typ) {
+				return false
+			}
+		}
+		return x.param(i, texts, typ, "")
+	case *ast.Ident:
+		obj0, class := x.insts[0].class(n)
+		for k, inst := range x.insts {
+			obj, cl := inst.class(inst.items[i].node.(*ast.Ident))
+			if cl != class {
+				return false
+			}
+			if class == "global" {
+				continue
+			}
+			// the variables must be renamed consistently
+			if prev, ok := x.vars[k][obj]; ok && prev != obj0 || !types.Identical(obj.Type(), obj0.Type()) {
+				return false
+			}
+			x.vars[k][obj] = obj0
+		}
+		switch {
+		case class != "global":
+			if _, ok := x.vars[0][obj0]; ok && !contains(x.order, obj0) {
+				x.order = append(x.order, obj0)
+				x.locals[obj0] = class == "local"
+			}
+			return x.consistent()
+		case same:
+			return true
+		}
+		// differing package-level variables, constants and functions
+		switch obj0.(type) {
+		case *types.Var, *types.Const, *types.Func:
+		default:
+			return false
+		}
+		if obj0.Parent() != x.insts[0].pkg.Scope() {
+			return false
+		}
+		typ := types.Default(obj0.Type())
+		for _, inst := range x.insts[1:] {
+			obj, _ := inst.class(inst.items[i].node.(*ast.Ident))
+			if obj == nil || !types.Identical(types.Default(obj.Type()), typ) {
+				return false
+			}
+		}
+		return x.param(i, texts, typ, obj0.Name())
+	}
+	return same
+}
+
+// consistent reports whether each variable of the first instance
+// corresponds to a single variable of each other instance.
+func (x *extraction) consistent() bool {
+	for _, vars := range x.vars {
+		seen := make(map[types.Object]bool)
+		for _, obj0 := range vars {
+			if seen[obj0] {
+				return false
+			}
+			seen[obj0] = true
+		}
+	}
+	return true
+}
+
+// param replaces the i-th items of the instances by a parameter.
+func (x *extraction) param(i int, texts []string, typ types.Type, name string) bool {
+	if typ == nil || typ == types.Typ[types.Invalid] {
+		return false
+	}
+	key := strings.Join(texts, "\x00")
+	p, ok := x.params[key]
+	if !ok {
+		p = &extractParam{texts: texts, typ: typ, name: name}
+		x.params[key] = p
+		x.paramList = append(x.paramList, p)
+	}
+	x.replaced[i] = p
+	return true
+}
+
+// suggest returns the function of the given name extracted from
+// the fragments.
+func (x *extraction) suggest(name string) (Extraction, bool) {
+	qual := x.qualifier()
+
+	// the variables of each instance by those of the first one
+	vars := make([]map[types.Object]types.Object, len(x.insts))
+	for k := range x.insts {
+		vars[k] = make(map[types.Object]types.Object)
+		for obj, obj0 := range x.vars[k] {
+			vars[k][obj0] = obj
+		}
+	}
+
+	var params, results []string
+	args := make([][]string, len(x.insts))
+	outs := make([][]string, len(x.insts))
+	decls := make([][]string, len(x.insts))
+	var assigned bool // whether some results are declared before
+	for _, obj := range x.order {
+		if obj.Type() == types.Typ[types.Invalid] {
+			return Extraction{}, false
+		}
+		typ := types.TypeString(obj.Type(), qual)
+		var written, usedAfter bool
+		for k, inst := range x.insts {
+			written = written || inst.written[vars[k][obj]]
+			usedAfter = usedAfter || inst.lastUse[vars[k][obj]] > inst.end
+		}
+		local := x.locals[obj]
+		if !local {
+			params = append(params, obj.Name()+" "+typ)
+			for k := range x.insts {
+				args[k] = append(args[k], vars[k][obj].Name())
+			}
+		}
+		if local && usedAfter || !local && written {
+			results = append(results, typ)
+			for k := range x.insts {
+				name := vars[k][obj].Name()
+				outs[k] = append(outs[k], name)
+				if local {
+					decls[k] = append(decls[k], fmt.Sprintf("var %s %s; ", name, typ))
+				}
+			}
+			assigned = assigned || !local
+		}
+	}
+	// the parameters must not shadow what the function uses
+	names := x.paramNames(x.kept())
+	for _, p := range x.paramList {
+		params = append(params, names[p]+" "+types.TypeString(p.typ, qual))
+		for k := range x.insts {
+			args[k] = append(args[k], p.texts[k])
+		}
+	}
+
+	sig := fmt.Sprintf("func %s(%s)", name, strings.Join(params, ", "))
+	switch len(results) {
+	case 0:
+	case 1:
+		sig += " " + results[0]
+	default:
+		sig += " (" + strings.Join(results, ", ") + ")"
+	}
+	ext := Extraction{Func: sig, Decl: x.decl(sig, names, outs[0])}
+	for k := range x.insts {
+		call := fmt.Sprintf("%s(%s)", name, strings.Join(args[k], ", "))
+		switch {
+		case len(outs[k]) == 0:
+		case !assigned:
+			call = strings.Join(outs[k], ", ") + " := " + call
+		default:
+			// declare the new variables first
+			call = strings.Join(decls[k], "") + strings.Join(outs[k], ", ") + " = " + call
+		}
+		ext.Calls = append(ext.Calls, call)
+	}
+	return ext, true
+}
+
+// qualifier qualifies the types of the other packages than that of
+// the first instance by the names of the packages.
+func (x *extraction) qualifier() types.Qualifier {
+	return func(pkg *types.Package) string {
+		if pkg == x.insts[0].pkg {
+			return ""
+		}
+		return pkg.Name()
+	}
+}
+
+// paramNames returns the names of the parameters, which differ from
+// the used names. The used names are updated.
+func (x *extraction) paramNames(used map[string]bool) map[*extractParam]string {
+	names := make(map[*extractParam]string)
+	for _, p := range x.paramList {
+		name := p.name
+		if name == "" || used[name] {
+			name = paramNameOf(p.typ, used)
+		}
+		names[p], used[name] = name, true
+	}
+	return names
+}
+
+// kept returns the identifiers of the first instance not replaced by
+// parameters.
+func (x *extraction) kept() map[string]bool {
+	kept := make(map[string]bool)
+	for i, it := range x.insts[0].items {
+		if id, ok := it.node.(*ast.Ident); ok && x.replaced[i] == nil {
+			kept[id.Name] = true
+		}
+	}
+	return kept
+}
+
+// decl returns the declaration of the function of the signature whose
+// body are the statements of the first instance with the parameters in
+// place of the differing texts.
+func (x *extraction) decl(sig string, names map[*extractParam]string, results []string) string {
+	var b strings.Builder
+	b.WriteString(sig + " {\n")
+	b.WriteString(x.body(names))
+	if len(results) > 0 {
+		b.WriteString("\nreturn " + strings.Join(results, ", "))
+	}
+	b.WriteString("\n}\n")
+	return b.String()
+}
+
+// body returns the statements of the first instance with the given names
+// of the parameters in place of the differing texts.
+func (x *extraction) body(names map[*extractParam]string) string {
+	first := x.insts[0]
+	src := first.srcs[first.tf.Name()]
+	var b strings.Builder
+	off := first.tf.Offset(first.start)
+	for i, it := range first.items {
+		if p, ok := x.replaced[i]; ok {
+			b.Write(src[off:first.tf.Offset(it.node.Pos())])
+			b.WriteString(names[p])
+			off = first.tf.Offset(it.node.End())
+		}
+	}
+	b.Write(src[off:first.tf.Offset(first.end)])
+	return b.String()
+}
+
+// paramNameOf returns an unused name of a parameter of the type.
+func paramNameOf(typ types.Type, used map[string]bool) string {
+	base := "v"
+	if b, ok := typ.Underlying().(*types.Basic); ok {
+		switch {
+		case b.Info()&types.IsString != 0:
+			base = "s"
+		case b.Info()&types.IsInteger != 0:
+			base = "n"
+		case b.Info()&types.IsFloat != 0:
+			base = "f"
+		}
+	} else if _, ok := typ.(*types.Signature); ok {
+		base = "fn"
+	}
+	name := base
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprint(base, i)
+	}
+	used[name] = true
+	return name
+}
+
+func contains(objs []types.Object, obj types.Object) bool {
+	for _, o := range objs {
+		if o == obj {
+			return true
+		}
+	}
+	return false
+}
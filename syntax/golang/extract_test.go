@@ -0,0 +1,201 @@
+package golang
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+const extractSrc = `package p
+
+const limit = 10
+
+const maxLen = 20
+
+func first(names []string, out []string) []string {
+	count := 0
+	for _, name := range names {
+		if len(name) > limit {
+			continue
+		}
+		out = append(out, "a:"+name)
+		count++
+	}
+	println(count)
+	return out
+}
+
+func second(items []string, res []string) []string {
+	n := 0
+	for _, item := range items {
+		if len(item) > maxLen {
+			continue
+		}
+		res = append(res, "b:"+item)
+		n++
+	}
+	println(n)
+	return res[:n]
+}
+
+func third(items []string) int {
+	for _, item := range items {
+		if len(item) > maxLen {
+			return 0
+		}
+	}
+	return 1
+}
+
+func fourth(items []string) int {
+	for _, item := range items {
+		if len(item) > limit {
+			return 0
+		}
+	}
+	return 1
+}
+
+func returnA(xs []int) {
+	for _, x := range xs {
+		if x < 0 {
+			return
+		}
+		println(x + 1)
+		continue
+	}
+	println("done")
+}
+
+func returnB(xs []int) {
+	for _, x := range xs {
+		if x < 0 {
+			return
+		}
+		println(x + 2)
+		continue
+	}
+	println("done")
+}
+
+func deferA(xs []int) {
+	for _, x := range xs {
+		defer println(x + 1)
+		continue
+	}
+}
+
+func deferB(xs []int) {
+	for _, x := range xs {
+		defer println(x + 2)
+		continue
+	}
+}
+
+func labelA(xs []int) {
+loop:
+	for _, x := range xs {
+		println(x + 1)
+		continue
+	}
+}
+
+func labelB(xs []int) {
+loop:
+	for _, x := range xs {
+		println(x + 2)
+		continue
+	}
+}
+
+func gotoA(xs []int) {
+	for _, x := range xs {
+		if x < 0 {
+			goto end
+		}
+		println(x + 1)
+		continue
+	}
+end:
+	println("done")
+}
+
+func gotoB(xs []int) {
+	for _, x := range xs {
+		if x < 0 {
+			goto end
+		}
+		println(x + 2)
+		continue
+	}
+end:
+	println("done")
+}
+
+func check(x int, m ...map[string]int) {}
+
+func itemsA(xs []int) {
+	for _, x := range xs {
+		check(x, map[string]int{"a": 1})
+	}
+}
+
+func itemsB(xs []int) {
+	for _, x := range xs {
+		check(x)
+	}
+}
+`
+
+func TestSuggestExtract(t *testing.T) {
+	filename := writeSrc(t, extractSrc)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// stmts returns the n statements of the body of the function fn
+	// starting with the i-th one.
+	stmts := func(fn string, i, n int) []*syntax.Node {
+		var frag []*syntax.Node
+		for _, decl := range file.Decls {
+			if decl, ok := decl.(*ast.FuncDecl); ok && decl.Name.Name == fn {
+				for _, stmt := range decl.Body.List[i : i+n] {
+					frag = append(frag, &syntax.Node{Filename: filename,
+						Pos: fset.Position(stmt.Pos()).Offset, End: fset.Position(stmt.End()).Offset})
+				}
+			}
+		}
+		return frag
+	}
+	testCases := []struct {
+		frags  [][]*syntax.Node
+		expect string
+	}{
+		{
+			[][]*syntax.Node{stmts("first", 1, 1), stmts("second", 1, 1)},
+			"func extracted(names []string, out []string, count int, limit int, s string) ([]string, int)\n" +
+				"out, count = extracted(names, out, count, limit, \"a:\")\n" +
+				"res, n = extracted(items, res, n, maxLen, \"b:\")",
+		},
+		{[][]*syntax.Node{stmts("third", 0, 1), stmts("fourth", 0, 1)}, ""},
+		{[][]*syntax.Node{stmts("returnA", 0, 1), stmts("returnB", 0, 1)}, ""},
+		{[][]*syntax.Node{stmts("deferA", 0, 1), stmts("deferB", 0, 1)}, ""},
+		{[][]*syntax.Node{stmts("labelA", 0, 1), stmts("labelB", 0, 1)}, ""},
+		{[][]*syntax.Node{stmts("gotoA", 0, 1), stmts("gotoB", 0, 1)}, ""},
+		{[][]*syntax.Node{stmts("itemsA", 0, 1), stmts("itemsB", 0, 1)}, ""},
+	}
+	for _, tc := range testCases {
+		var actual string
+		if x, ok := new(Config).SuggestExtract(tc.frags); ok {
+			actual = strings.Join(append([]string{x.Func}, x.Calls...), "\n")
+		}
+		if actual != tc.expect {
+			t.Errorf("got\n%s\nwant\n%s", actual, tc.expect)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package golang
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// A Fixer rewrites clones into calls of functions extracted from them.
+type Fixer struct {
+	c     *Config
+	edits map[string][]edit // by filenames
+	fixed map[string][]byte // the rewritten files
+	names map[string]bool   // the names of the extracted functions
+}
+
+// An edit replaces the source from pos to end by text.
+type edit struct {
+	pos, end int
+	text     string
+}
+
+// A FixedFile is a file rewritten by a Fixer.
+type FixedFile struct {
+	Filename   string
+	Src, Fixed []byte
+}
+
+func (c *Config) NewFixer() *Fixer {
+	return &Fixer{
+		c:     c,
+		edits: make(map[string][]edit),
+		fixed: make(map[string][]byte),
+		names: make(map[string]bool),
+	}
+}
+
+// Fix rewrites the clones into calls of a function extracted from them,
+// see SuggestExtract, which is added to the file of the first clone.
+// The clones must be in a single package that type-checks before and
+// after the rewrite and the control flow must not leave them other than
+// at their ends. Fix reports whether the clones were rewritten.
+func (f *Fixer) Fix(frags [][]*syntax.Node) bool {
+	x, ok := f.c.align(frags, true)
+	if !ok {
+		return false
+	}
+	p := x.insts[0].checkedPkg
+	if p.err != nil {
+		return false
+	}
+	for _, inst := range x.insts {
+		// the type checks would not catch the changed control flow
+		if inst.checkedPkg != p || leaves(inst.stmts, false, false) {
+			return false
+		}
+	}
+	name := f.name(p)
+	ext, ok := x.suggest(name)
+	if !ok {
+		return false
+	}
+
+	edits := make(map[string][]edit)
+	for filename, es := range f.edits {
+		edits[filename] = append([]edit(nil), es...)
+	}
+	for k, inst := range x.insts {
+		filename := inst.tf.Name()
+		edits[filename] = append(edits[filename],
+			edit{inst.tf.Offset(inst.start), inst.tf.Offset(inst.end), ext.Calls[k]})
+	}
+	first := x.insts[0].tf.Name()
+	end := len(p.srcs[first])
+	edits[first] = append(edits[first], edit{end, end, "\n" + ext.Decl})
+
+	fixed, ok := f.check(p, edits)
+	if !ok {
+		return false
+	}
+	f.edits = edits
+	for filename, src := range fixed {
+		f.fixed[filename] = src
+	}
+	f.names[p.pkg.Path()+"."+name] = true
+	return true
+}
+
+// leaves reports whether the control flow may leave the statements other
+// than at their end: by returning, deferring a call to the end of the
+// enclosing function, jumping to a label or breaking or continuing
+// a statement enclosing them. The statements are nested in a loop or
+// a breakable statement if loop or breakable is set.
+func leaves(stmts []ast.Stmt, loop, breakable bool) bool {
+	for _, stmt := range stmts {
+		var body []ast.Stmt
+		innerLoop, innerBreakable := loop, breakable
+		switch s := stmt.(type) {
+		case *ast.ReturnStmt, *ast.DeferStmt, *ast.LabeledStmt:
+			return true
+		case *ast.BranchStmt:
+			if s.Label != nil || s.Tok == token.GOTO ||
+				s.Tok == token.BREAK && !breakable || s.Tok == token.CONTINUE && !loop {
+				return true
+			}
+		case *ast.BlockStmt:
+			body = s.List
+		case *ast.IfStmt:
+			body = []ast.Stmt{s.Body}
+			if s.Else != nil {
+				body = append(body, s.Else)
+			}
+		case *ast.ForStmt:
+			body, innerLoop, innerBreakable = s.Body.List, true, true
+		case *ast.RangeStmt:
+			body, innerLoop, innerBreakable = s.Body.List, true, true
+		case *ast.SwitchStmt:
+			body, innerBreakable = s.Body.List, true
+		case *ast.TypeSwitchStmt:
+			body, innerBreakable = s.Body.List, true
+		case *ast.SelectStmt:
+			body, innerBreakable = s.Body.List, true
+		case *ast.CaseClause:
+			body = s.Body
+		case *ast.CommClause:
+			body = s.Body
+		}
+		if leaves(body, innerLoop, innerBreakable) {
+			return true
+		}
+	}
+	return false
+}
+
+// name returns an unused name of a function extracted to the package.
+func (f *Fixer) name(p *checkedPkg) string {
+	name := "extracted"
+	for i := 2; p.pkg.Scope().Lookup(name) != nil || f.names[p.pkg.Path()+"."+name]; i++ {
+		name = fmt.Sprint("extracted", i)
+	}
+	return name
+}
+
+// check applies the edits to the files of the package and reports
+// whether the package still type-checks. The imports that are no longer
+// used are removed. It returns the rewritten files.
+func (f *Fixer) check(p *checkedPkg, edits map[string][]edit) (map[string][]byte, bool) {
+	fixed := make(map[string][]byte)
+	for filename := range p.files {
+		if es, ok := edits[filename]; ok {
+			src, ok := apply(p.srcs[filename], es)
+			if !ok {
+				return nil, false
+			}
+			fixed[filename] = src
+		}
+	}
+
+	for retry := true; ; retry = false {
+		fset := token.NewFileSet()
+		var files []*ast.File
+		for filename := range p.files {
+			src, ok := fixed[filename]
+			if !ok {
+				src = p.srcs[filename]
+			}
+			file, err := parser.ParseFile(fset, filename, src, 0)
+			if err != nil {
+				return nil, false
+			}
+			files = append(files, file)
+		}
+		var unused []types.Error
+		var failed bool
+		conf := types.Config{
+			Importer: f.c.types.importer,
+			Error: func(err error) {
+				if err, ok := err.(types.Error); ok && strings.HasSuffix(err.Msg, "imported and not used") {
+					unused = append(unused, err)
+				} else {
+					failed = true
+				}
+			},
+		}
+		conf.Check(p.pkg.Path(), fset, files, nil)
+		if failed || len(unused) > 0 && !retry {
+			return nil, false
+		}
+		if len(unused) == 0 {
+			return fixed, true
+		}
+		for _, file := range files {
+			filename := fset.File(file.Pos()).Name()
+			var imports []token.Pos
+			for _, err := range unused {
+				if fset.Position(err.Pos).Filename == filename {
+					imports = append(imports, err.Pos)
+				}
+			}
+			if len(imports) == 0 {
+				continue
+			}
+			src, ok := fixed[filename]
+			if !ok {
+				return nil, false
+			}
+			if fixed[filename], ok = dropImports(src, fset, file, imports); !ok {
+				return nil, false
+			}
+		}
+	}
+}
+
+// apply applies the edits to the source and formats the result. It reports
+// whether the edits do not overlap and the result is valid.
+func apply(src []byte, edits []edit) ([]byte, bool) {
+	edits = append([]edit(nil), edits...)
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].pos < edits[j].pos })
+	var buf bytes.Buffer
+	off := 0
+	for _, e := range edits {
+		if e.pos < off {
+			return nil, false
+		}
+		buf.Write(src[off:e.pos])
+		buf.WriteString(e.text)
+		off = e.end
+	}
+	buf.Write(src[off:])
+	fixed, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, false
+	}
+	return fixed, true
+}
+
+// dropImports removes the imports at the positions from the source
+// of the file.
+func dropImports(src []byte, fset *token.FileSet, file *ast.File, imports []token.Pos) ([]byte, bool) {
+	var edits []edit
+	for _, decl := range file.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.IMPORT {
+			continue
+		}
+		var dropped []ast.Node
+		for _, spec := range decl.Specs {
+			for _, pos := range imports {
+				if spec.Pos() <= pos && pos < spec.End() {
+					dropped = append(dropped, spec)
+				}
+			}
+		}
+		if len(dropped) == len(decl.Specs) {
+			dropped = []ast.Node{decl}
+		}
+		for _, n := range dropped {
+			edits = append(edits, edit{fset.Position(n.Pos()).Offset, fset.Position(n.End()).Offset, ""})
+		}
+	}
+	return apply(src, edits)
+}
+
+// Files returns the files rewritten so far sorted by their names.
+func (f *Fixer) Files() []FixedFile {
+	var files []FixedFile
+	for filename, fixed := range f.fixed {
+		p, _ := f.c.checkPackage(filename)
+		files = append(files, FixedFile{filename, p.srcs[filename], fixed})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Filename < files[j].Filename })
+	return files
+}
@@ -0,0 +1,157 @@
+package golang
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+var fixSrcs = []string{`package p
+
+import "strings"
+
+func first(names []string) int {
+	n := 0
+	for _, name := range names {
+		if strings.HasPrefix(name, "a") {
+			n++
+		}
+	}
+	return n
+}
+`, `package p
+
+import "strings"
+
+func second(items []string) int {
+	n := 0
+	for _, item := range items {
+		if strings.HasPrefix(item, "b") {
+			n++
+		}
+	}
+	return n
+}
+`}
+
+func TestFix(t *testing.T) {
+	names := writeFiles(t, map[string]string{"a.go": fixSrcs[0], "b.go": fixSrcs[1]})
+	var frags [][]*syntax.Node
+	for i, src := range fixSrcs {
+		filename := names[[]string{"a.go", "b.go"}[i]]
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, filename, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loop := file.Decls[1].(*ast.FuncDecl).Body.List[1]
+		frags = append(frags, []*syntax.Node{{Filename: filename,
+			Pos: fset.Position(loop.Pos()).Offset, End: fset.Position(loop.End()).Offset}})
+	}
+
+	fixer := new(Config).NewFixer()
+	if !fixer.Fix(frags) {
+		t.Fatal("clones not fixed")
+	}
+	files := fixer.Files()
+	if len(files) != 2 {
+		t.Fatalf("got %d fixed files, want 2", len(files))
+	}
+	expect := []string{
+		"n = extracted(names, n, \"a\")",
+		"n = extracted(items, n, \"b\")",
+	}
+	for i, f := range files {
+		fixed := string(f.Fixed)
+		if !strings.Contains(fixed, expect[i]) {
+			t.Errorf("%s does not contain %q:\n%s", f.Filename, expect[i], fixed)
+		}
+	}
+	if fixed := string(files[0].Fixed); !strings.Contains(fixed, "func extracted(names []string, n int, s string) int {") {
+		t.Errorf("the extracted function not found in:\n%s", fixed)
+	}
+	if fixed := string(files[1].Fixed); strings.Contains(fixed, "import") {
+		t.Errorf("the unused import not removed:\n%s", fixed)
+	}
+	if fixer.Fix(frags) {
+		t.Error("clones fixed twice")
+	}
+}
+
+func TestFixRejectsControlFlow(t *testing.T) {
+	testCases := []struct {
+		name string
+		body string // the body of the loop of the clones, %d differs
+	}{
+		{"return", "if x < 0 {\n\t\t\treturn\n\t\t}\n\t\tprintln(x + %d)\n\t\tcontinue"},
+		{"defer", "defer println(x + %d)\n\t\tcontinue"},
+		{"goto", "if x < 0 {\n\t\t\tgoto end\n\t\t}\n\t\tprintln(x + %d)\n\t\tcontinue"},
+		{"label", "inner:\n\t\tfor {\n\t\t\tprintln(x + %d)\n\t\t\tbreak inner\n\t\t}"},
+	}
+	for _, tc := range testCases {
+		var b strings.Builder
+		b.WriteString("package p\n")
+		for i, name := range []string{"a", "b"} {
+			body := strings.Replace(tc.body, "%d", []string{"1", "2"}[i], 1)
+			b.WriteString("\nfunc " + name + "(xs []int) {\n\tfor _, x := range xs {\n\t\t" + body +
+				"\n\t}\n\tgoto end\nend:\n\tprintln(\"done\")\n}\n")
+		}
+		src := b.String()
+		filename := writeSrc(t, src)
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, filename, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var frags [][]*syntax.Node
+		for _, decl := range file.Decls {
+			loop := decl.(*ast.FuncDecl).Body.List[0]
+			frags = append(frags, []*syntax.Node{{Filename: filename,
+				Pos: fset.Position(loop.Pos()).Offset, End: fset.Position(loop.End()).Offset}})
+		}
+
+		fixer := new(Config).NewFixer()
+		if fixer.Fix(frags) || len(fixer.Files()) > 0 {
+			t.Errorf("%s: clones fixed", tc.name)
+		}
+	}
+}
+
+func TestLeaves(t *testing.T) {
+	testCases := []struct {
+		stmts  string
+		leaves bool
+	}{
+		{"x++", false},
+		{"return", true},
+		{"defer f()", true},
+		{"goto end", true},
+		{"l: x++", true},
+		{"break", true},
+		{"continue", true},
+		{"for { break }", false},
+		{"for { continue }", false},
+		{"switch { case true: break }", false},
+		{"switch { case true: continue }", true},
+		{"for { switch { case true: continue } }", false},
+		{"for { break l }", true},
+		{"if x > 0 { x++ } else { return }", true},
+		{"f := func() { return }; f()", false},
+		{"select { case <-c: return }", true},
+	}
+	for _, tc := range testCases {
+		src := "package p\nfunc f() {\n" + tc.stmts + "\n}\n"
+		file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stmts := file.Decls[0].(*ast.FuncDecl).Body.List
+		if got := leaves(stmts, false, false); got != tc.leaves {
+			t.Errorf("%s: got %v, want %v", tc.stmts, got, tc.leaves)
+		}
+	}
+}
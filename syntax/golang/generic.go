@@ -0,0 +1,369 @@
+package golang
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// SuggestGeneric returns the signature of a generic function that could
+// replace the functions of the fragments if they differ only in types.
+// The type parameters are constrained by the unions of the differing types.
+func SuggestGeneric(frags [][]*syntax.Node, readFile func(string) ([]byte, error)) (string, bool) {
+	funcs := make([]*ast.FuncDecl, len(frags))
+	srcs := make([][]byte, len(frags))
+	for i, frag := range frags {
+		if len(frag) != 1 || Kind(frag[0].Type) != FuncDecl {
+			return "", false
+		}
+		src, err := readFile(frag[0].Filename)
+		if err != nil {
+			return "", false
+		}
+		fn := funcAt(src, frag[0].Pos)
+		if fn == nil || fn.Recv != nil || fn.Type.TypeParams != nil {
+			return "", false
+		}
+		funcs[i], srcs[i] = fn, src
+	}
+
+	// the functions must be the same except for the types
+	var leaves [][]leaf
+	for i, fn := range funcs {
+		l := flatten(fn, srcs[i])
+		if i > 0 && !sameLeaves(leaves[0], l) {
+			return "", false
+		}
+		leaves = append(leaves, l)
+	}
+	var differ bool
+	for j, l := range leaves[0] {
+		for _, l2 := range leaves[1:] {
+			differ = differ || l.typ != nil && l.text != l2[j].text
+		}
+	}
+	if !differ {
+		return "", false
+	}
+
+	g := &generic{srcs: srcs, params: make(map[string]*typeParam)}
+	params := g.fields(funcs, func(fn *ast.FuncDecl) *ast.FieldList { return fn.Type.Params })
+	results := g.fields(funcs, func(fn *ast.FuncDecl) *ast.FieldList { return fn.Type.Results })
+	if results != "" && (strings.Contains(results, " ") || strings.Contains(results, ",")) {
+		results = "(" + results + ")"
+	}
+	for j, l := range leaves[0] {
+		if l.typ == nil {
+			continue
+		}
+		// the types in the bodies may need type parameters too
+		types := make([]ast.Expr, len(leaves))
+		for i := range leaves {
+			types[i] = leaves[i][j].typ
+		}
+		g.typ(types, false)
+	}
+	var tparams []string
+	for _, p := range g.order {
+		tparams = append(tparams, p.name+" "+p.constraint())
+	}
+	sig := fmt.Sprintf("func %s[%s](%s)", genericName(funcs), strings.Join(tparams, ", "), params)
+	if results != "" {
+		sig += " " + results
+	}
+	return sig, true
+}
+
+// funcAt returns the function declared at the offset of the source.
+func funcAt(src []byte, offset int) *ast.FuncDecl {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil
+	}
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fset.Position(fn.Pos()).Offset == offset {
+			return fn
+		}
+	}
+	return nil
+}
+
+// A leaf is a node of a flattened function. Type expressions are not
+// flattened further.
+type leaf struct {
+	kind string
+	text string   // identifier, literal, operator or type expression
+	typ  ast.Expr // the type expression, or nil
+}
+
+// flatten flattens the function in the preorder. The function name is
+// skipped.
+func flatten(fn *ast.FuncDecl, src []byte) []leaf {
+	types := typeExprs(fn)
+	var leaves []leaf
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if n == nil || n == fn.Name {
+			return false
+		}
+		if x, ok := n.(ast.Expr); ok && types[x] {
+			leaves = append(leaves, leaf{kind: "type", text: source(src, x), typ: x})
+			return false
+		}
+		l := leaf{kind: fmt.Sprintf("%T", n)}
+		switch n := n.(type) {
+		case *ast.Ident:
+			l.text = n.Name
+		case *ast.BasicLit:
+			l.text = n.Value
+		case *ast.BinaryExpr:
+			l.text = n.Op.String()
+		case *ast.UnaryExpr:
+			l.text = n.Op.String()
+		case *ast.AssignStmt:
+			l.text = n.Tok.String()
+		case *ast.IncDecStmt:
+			l.text = n.Tok.String()
+		case *ast.BranchStmt:
+			l.text = n.Tok.String()
+		}
+		leaves = append(leaves, l)
+		return true
+	})
+	return leaves
+}
+
+// typeExprs returns the expressions in type positions.
+func typeExprs(n ast.Node) map[ast.Expr]bool {
+	types := make(map[ast.Expr]bool)
+	add := func(x ast.Expr) {
+		if x != nil {
+			types[x] = true
+		}
+	}
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Field:
+			add(n.Type)
+		case *ast.ValueSpec:
+			add(n.Type)
+		case *ast.CompositeLit:
+			add(n.Type)
+		case *ast.TypeAssertExpr:
+			add(n.Type)
+		case *ast.TypeSpec:
+			add(n.Type)
+		case *ast.CallExpr:
+			if (isIdent(n.Fun, "make") || isIdent(n.Fun, "new")) && len(n.Args) > 0 {
+				add(n.Args[0])
+			} else if isType(n.Fun) {
+				add(n.Fun)
+			}
+		}
+		return true
+	})
+	return types
+}
+
+func sameLeaves(a, b []leaf) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].kind != b[i].kind || a[i].typ == nil && a[i].text != b[i].text {
+			return false
+		}
+	}
+	return true
+}
+
+func source(src []byte, n ast.Node) string {
+	return string(src[n.Pos()-1 : n.End()-1])
+}
+
+// generic collects the type parameters of a generic function.
+type generic struct {
+	srcs   [][]byte
+	params map[string]*typeParam // by the differing types
+	order  []*typeParam
+}
+
+type typeParam struct {
+	name       string
+	types      []string
+	comparable bool
+}
+
+func (p *typeParam) constraint() string {
+	seen := make(map[string]bool)
+	var union []string
+	for _, typ := range p.types {
+		if !predeclaredTypes[typ] {
+			if p.comparable {
+				return "comparable"
+			}
+			return "any"
+		}
+		if !seen[typ] {
+			seen[typ] = true
+			union = append(union, typ)
+		}
+	}
+	return strings.Join(union, " | ")
+}
+
+// fields returns the generic source of the corresponding field lists
+// of the functions.
+func (g *generic) fields(funcs []*ast.FuncDecl, list func(*ast.FuncDecl) *ast.FieldList) string {
+	if list(funcs[0]) == nil {
+		return ""
+	}
+	var fields []string
+	for i, field := range list(funcs[0]).List {
+		types := make([]ast.Expr, len(funcs))
+		for j, fn := range funcs {
+			types[j] = list(fn).List[i].Type
+		}
+		var names []string
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+		typ := g.typ(types, false)
+		if len(names) > 0 {
+			typ = strings.Join(names, ", ") + " " + typ
+		}
+		fields = append(fields, typ)
+	}
+	return strings.Join(fields, ", ")
+}
+
+// typ returns the generic source of the corresponding types, whose
+// differing parts are replaced by type parameters.
+func (g *generic) typ(types []ast.Expr, comparable bool) string {
+	texts := make([]string, len(types))
+	for i, typ := range types {
+		texts[i] = source(g.srcs[i], typ)
+	}
+	same := true
+	for _, text := range texts[1:] {
+		same = same && text == texts[0]
+	}
+	if same {
+		return texts[0]
+	}
+
+	parts := func(part func(ast.Expr) ast.Expr) []ast.Expr {
+		xs := make([]ast.Expr, len(types))
+		for i, typ := range types {
+			xs[i] = part(typ)
+		}
+		return xs
+	}
+	switch x := types[0].(type) {
+	case *ast.ArrayType:
+		if g.sameShape(types, func(i int, typ ast.Expr) string {
+			if a, ok := typ.(*ast.ArrayType); ok && a.Len == nil {
+				return "[]"
+			} else if ok {
+				return "[" + source(g.srcs[i], a.Len) + "]"
+			}
+			return ""
+		}) {
+			return texts[0][:x.Elt.Pos()-x.Pos()] +
+				g.typ(parts(func(typ ast.Expr) ast.Expr { return typ.(*ast.ArrayType).Elt }), false)
+		}
+	case *ast.MapType:
+		if g.sameShape(types, func(i int, typ ast.Expr) string {
+			if _, ok := typ.(*ast.MapType); ok {
+				return "map"
+			}
+			return ""
+		}) {
+			return "map[" + g.typ(parts(func(typ ast.Expr) ast.Expr { return typ.(*ast.MapType).Key }), true) + "]" +
+				g.typ(parts(func(typ ast.Expr) ast.Expr { return typ.(*ast.MapType).Value }), false)
+		}
+	case *ast.StarExpr:
+		if g.sameShape(types, func(i int, typ ast.Expr) string {
+			if _, ok := typ.(*ast.StarExpr); ok {
+				return "*"
+			}
+			return ""
+		}) {
+			return "*" + g.typ(parts(func(typ ast.Expr) ast.Expr { return typ.(*ast.StarExpr).X }), false)
+		}
+	case *ast.Ellipsis:
+		if g.sameShape(types, func(i int, typ ast.Expr) string {
+			if _, ok := typ.(*ast.Ellipsis); ok {
+				return "..."
+			}
+			return ""
+		}) {
+			return "..." + g.typ(parts(func(typ ast.Expr) ast.Expr { return typ.(*ast.Ellipsis).Elt }), false)
+		}
+	case *ast.ChanType:
+		if g.sameShape(types, func(i int, typ ast.Expr) string {
+			if c, ok := typ.(*ast.ChanType); ok {
+				return fmt.Sprint("chan", c.Dir)
+			}
+			return ""
+		}) {
+			return texts[0][:x.Value.Pos()-x.Pos()] +
+				g.typ(parts(func(typ ast.Expr) ast.Expr { return typ.(*ast.ChanType).Value }), false)
+		}
+	}
+	return g.param(texts, comparable)
+}
+
+// sameShape reports whether the types are of the same non-empty shape.
+func (g *generic) sameShape(types []ast.Expr, shape func(i int, typ ast.Expr) string) bool {
+	s := shape(0, types[0])
+	for i, typ := range types[1:] {
+		if shape(i+1, typ) != s {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// param returns the type parameter standing for the types.
+func (g *generic) param(types []string, comparable bool) string {
+	key := strings.Join(types, "\x00")
+	p, ok := g.params[key]
+	if !ok {
+		p = &typeParam{name: paramName(len(g.order)), types: types}
+		g.params[key] = p
+		g.order = append(g.order, p)
+	}
+	p.comparable = p.comparable || comparable
+	return p.name
+}
+
+func paramName(i int) string {
+	names := []string{"T", "U", "V", "W"}
+	if i < len(names) {
+		return names[i]
+	}
+	return fmt.Sprintf("T%d", i)
+}
+
+// genericName returns the common prefix of the names of the functions,
+// or the first name if the prefix is too short.
+func genericName(funcs []*ast.FuncDecl) string {
+	name := funcs[0].Name.Name
+	prefix := name
+	for _, fn := range funcs[1:] {
+		i := 0
+		for i < len(prefix) && i < len(fn.Name.Name) && prefix[i] == fn.Name.Name[i] {
+			i++
+		}
+		prefix = prefix[:i]
+	}
+	if len(prefix) < 3 {
+		return name
+	}
+	return prefix
+}
@@ -0,0 +1,75 @@
+package golang
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+const genericSrc = `package p
+
+func sumInts(xs []int) int {
+	var s int
+	for _, x := range xs {
+		s += x
+	}
+	return s
+}
+
+func sumFloats(xs []float64) float64 {
+	var s float64
+	for _, x := range xs {
+		s += x
+	}
+	return s
+}
+
+func sumPositive(xs []int) int {
+	var s int
+	for _, x := range xs {
+		s -= x
+	}
+	return s
+}
+
+func indexInt(m map[int]*int, xs []int) {
+	for i, x := range xs {
+		m[x] = &xs[i]
+	}
+}
+
+func indexPoint(m map[Point]*Point, xs []Point) {
+	for i, x := range xs {
+		m[x] = &xs[i]
+	}
+}
+`
+
+func TestSuggestGeneric(t *testing.T) {
+	readFile := func(string) ([]byte, error) { return []byte(genericSrc), nil }
+	fn := func(name string) []*syntax.Node {
+		pos := strings.Index(genericSrc, "func "+name)
+		return []*syntax.Node{{Type: FuncDecl, Filename: "p.go", Pos: pos}}
+	}
+	testCases := []struct {
+		funcs  []string
+		expect string
+	}{
+		{[]string{"sumInts", "sumFloats"}, "func sum[T int | float64](xs []T) T"},
+		{[]string{"sumInts", "sumPositive"}, ""},
+		{[]string{"sumInts", "sumInts"}, ""},
+		{[]string{"indexInt", "indexPoint"}, "func index[T comparable](m map[T]*T, xs []T)"},
+	}
+
+	for _, tc := range testCases {
+		var frags [][]*syntax.Node
+		for _, name := range tc.funcs {
+			frags = append(frags, fn(name))
+		}
+		actual, _ := SuggestGeneric(frags, readFile)
+		if actual != tc.expect {
+			t.Errorf("for %v, got '%s', want '%s'", tc.funcs, actual, tc.expect)
+		}
+	}
+}
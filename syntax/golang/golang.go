@@ -4,6 +4,10 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/mibk/dupl/syntax"
 )
@@ -61,29 +65,297 @@ const (
 	ValueSpec
 )
 
+// kindNames are the names of the node kinds.
+var kindNames = [...]string{
+	BadNode:        "BadNode",
+	File:           "File",
+	ArrayType:      "ArrayType",
+	AssignStmt:     "AssignStmt",
+	BasicLit:       "BasicLit",
+	BinaryExpr:     "BinaryExpr",
+	BlockStmt:      "BlockStmt",
+	BranchStmt:     "BranchStmt",
+	CallExpr:       "CallExpr",
+	CaseClause:     "CaseClause",
+	ChanType:       "ChanType",
+	CommClause:     "CommClause",
+	CompositeLit:   "CompositeLit",
+	DeclStmt:       "DeclStmt",
+	DeferStmt:      "DeferStmt",
+	Ellipsis:       "Ellipsis",
+	EmptyStmt:      "EmptyStmt",
+	ExprStmt:       "ExprStmt",
+	Field:          "Field",
+	FieldList:      "FieldList",
+	ForStmt:        "ForStmt",
+	FuncDecl:       "FuncDecl",
+	FuncLit:        "FuncLit",
+	FuncType:       "FuncType",
+	GenDecl:        "GenDecl",
+	GoStmt:         "GoStmt",
+	Ident:          "Ident",
+	IfStmt:         "IfStmt",
+	IncDecStmt:     "IncDecStmt",
+	IndexExpr:      "IndexExpr",
+	IndexListExpr:  "IndexListExpr",
+	InterfaceType:  "InterfaceType",
+	KeyValueExpr:   "KeyValueExpr",
+	LabeledStmt:    "LabeledStmt",
+	MapType:        "MapType",
+	ParenExpr:      "ParenExpr",
+	RangeStmt:      "RangeStmt",
+	ReturnStmt:     "ReturnStmt",
+	SelectStmt:     "SelectStmt",
+	SelectorExpr:   "SelectorExpr",
+	SendStmt:       "SendStmt",
+	SliceExpr:      "SliceExpr",
+	StarExpr:       "StarExpr",
+	StructType:     "StructType",
+	SwitchStmt:     "SwitchStmt",
+	TypeAssertExpr: "TypeAssertExpr",
+	TypeSpec:       "TypeSpec",
+	TypeSwitchStmt: "TypeSwitchStmt",
+	UnaryExpr:      "UnaryExpr",
+	ValueSpec:      "ValueSpec",
+}
+
+// KindByName returns the node kind of the given name, e.g. IfStmt.
+func KindByName(name string) (int, bool) {
+	for kind, n := range kindNames {
+		if n == name {
+			return kind, true
+		}
+	}
+	return 0, false
+}
+
+// Levels of detail distinguished in node types.
+const (
+	// Kinds distinguishes only kinds of nodes.
+	Kinds = iota
+	// Operators distinguishes also operators of expressions, assignments,
+	// and increment and decrement statements.
+	Operators
+	// Keywords distinguishes also branch statements, kinds of general
+	// declarations and basic literals, and channel directions.
+	Keywords
+)
+
+// kindBits is the number of low bits of a node type holding its kind.
+// The higher bits hold the details distinguished at the configured level.
+const kindBits = 8
+
+// Kind returns the kind of the node type without any details.
+func Kind(typ int) int {
+	if typ < 0 {
+		// the ends of streams and files are not nodes
+		return BadNode
+	}
+	return typ & (1<<kindBits - 1)
+}
+
+// IsFunc reports whether n is a function declaration or literal.
+func IsFunc(n *syntax.Node) bool {
+	kind := Kind(n.Type)
+	return kind == FuncDecl || kind == FuncLit
+}
+
+// IsStmt reports whether n is a statement. Blocks, empty and labeled
+// statements are not considered statements on their own.
+func IsStmt(n *syntax.Node) bool {
+	switch Kind(n.Type) {
+	case AssignStmt, BranchStmt, DeclStmt, DeferStmt, ExprStmt, ForStmt, GoStmt,
+		IfStmt, IncDecStmt, RangeStmt, ReturnStmt, SelectStmt, SendStmt,
+		SwitchStmt, TypeSwitchStmt:
+		return true
+	}
+	return false
+}
+
+// Config configures the transformation of Go source files.
+type Config struct {
+	// Level is the level of detail distinguished in node types.
+	Level int
+
+	// Normalize is a set of normalization rules applied to the tree.
+	Normalize int
+
+	// TypeCheck enables type-checking of whole packages, see ParsePackage.
+	TypeCheck bool
+
+	// Suppress lists the suppressors of subtrees excluded from the search.
+	Suppress []Suppressor
+
+	types      typeInfo
+	suppressed atomic.Int64
+	packages   sync.Map // filename -> package name
+	methods    sync.Map // methodKey -> Method
+	decls      sync.Map // filename -> []declRange
+	corpora    sync.Map // filename -> corpus
+}
+
+// SetCorpus sets the corpus of the nodes of the file parsed afterwards,
+// see syntax.Node.
+func (c *Config) SetCorpus(filename string, corpus int) {
+	c.corpora.Store(filename, corpus)
+}
+
+// corpus returns the corpus of the file, which is 0 by default.
+func (c *Config) corpus(filename string) int {
+	corpus, _ := c.corpora.Load(filename)
+	n, _ := corpus.(int)
+	return n
+}
+
+type declRange struct {
+	pos, end int
+	label    string
+}
+
+// Enclosing returns the label of the top-level declaration enclosing
+// the offset of the parsed file, e.g. (*Server).handleLogin for methods
+// or the name of the first declared type, variable or constant, or
+// an empty string if there is none.
+func (c *Config) Enclosing(filename string, offset int) string {
+	decls, _ := c.decls.Load(filename)
+	ranges, _ := decls.([]declRange)
+	for _, d := range ranges {
+		if d.pos <= offset && offset < d.end {
+			return d.label
+		}
+	}
+	return ""
+}
+
+// A Method describes a method declaration.
+type Method struct {
+	Recv      string // name of the receiver base type
+	Name      string
+	Signature string // types of the parameters and results, e.g. (io.Writer) error
+}
+
+type methodKey struct {
+	filename string
+	pos      int
+}
+
+// Method returns the method declared at the offset of the parsed file.
+func (c *Config) Method(filename string, offset int) (Method, bool) {
+	m, ok := c.methods.Load(methodKey{filename, offset})
+	if !ok {
+		return Method{}, false
+	}
+	return m.(Method), true
+}
+
+// Package returns the name in the package clause of the parsed file.
+func (c *Config) Package(filename string) string {
+	name, _ := c.packages.Load(filename)
+	pkg, _ := name.(string)
+	return pkg
+}
+
 // Parse the given file and return uniform syntax tree.
 func Parse(filename string) (*syntax.Node, error) {
+	return new(Config).Parse(filename)
+}
+
+// Parse the given file and return uniform syntax tree.
+func (c *Config) Parse(filename string) (*syntax.Node, error) {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, filename, nil, 0)
 	if err != nil {
 		return nil, err
 	}
 	t := &transformer{
+		Config:   c,
 		fileset:  fset,
 		filename: filename,
+		corpus:   c.corpus(filename),
+		imports:  importNames(file),
 	}
 	return t.trans(file), nil
 }
 
+// declRanges returns the ranges of the top-level declarations of the file
+// labeled by their names. The specifications of grouped declarations have
+// their own ranges.
+func declRanges(fset *token.FileSet, file *ast.File) []declRange {
+	var ranges []declRange
+	add := func(n ast.Node, label string) {
+		if label != "" {
+			ranges = append(ranges, declRange{fset.Position(n.Pos()).Offset, fset.Position(n.End()).Offset, label})
+		}
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			add(d, funcLabel(d))
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				var n ast.Node = spec
+				if len(d.Specs) == 1 {
+					n = d
+				}
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					add(n, s.Name.Name)
+				case *ast.ValueSpec:
+					add(n, s.Names[0].Name)
+				}
+			}
+		}
+	}
+	return ranges
+}
+
+// funcLabel returns the name of the function or, for methods, the name
+// qualified by the receiver type, e.g. (*Server).handleLogin.
+func funcLabel(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) != 1 {
+		return fn.Name.Name
+	}
+	typ := fn.Recv.List[0].Type
+	for {
+		paren, ok := typ.(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		typ = paren.X
+	}
+	if _, ok := typ.(*ast.StarExpr); ok {
+		return "(*" + recvName(typ) + ")." + fn.Name.Name
+	}
+	return recvName(typ) + "." + fn.Name.Name
+}
+
 type transformer struct {
+	*Config
 	fileset  *token.FileSet
 	filename string
+	corpus   int
+	imports  map[string]bool
+
+	// type information; nil unless type-checked
+	info *types.Info
+	pkg  *types.Package
+
+	template bool // whether transforming a template, see SuppressTemplate
+}
+
+// typ returns the node type of the given kind. The detail is included
+// if the configured level is at least the given level.
+func (t *transformer) typ(kind, level, detail int) int {
+	if t.Level < level {
+		return kind
+	}
+	return kind | detail<<kindBits
 }
 
 // trans transforms given golang AST to uniform tree structure.
 func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 	o = syntax.NewNode()
-	o.Filename = t.filename
+	o.Filename, o.Corpus = t.filename, t.corpus
 	st, end := node.Pos(), node.End()
 	o.Pos, o.End = t.fileset.File(st).Offset(st), t.fileset.File(end).Offset(end)
 
@@ -96,7 +368,7 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 		o.AddChildren(t.trans(n.Elt))
 
 	case *ast.AssignStmt:
-		o.Type = AssignStmt
+		o.Type = t.typ(AssignStmt, Operators, int(n.Tok))
 		for _, e := range n.Rhs {
 			o.AddChildren(t.trans(e))
 		}
@@ -106,10 +378,10 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 		}
 
 	case *ast.BasicLit:
-		o.Type = BasicLit
+		o.Type = t.typ(BasicLit, Keywords, int(n.Kind))
 
 	case *ast.BinaryExpr:
-		o.Type = BinaryExpr
+		o.Type = t.typ(BinaryExpr, Operators, int(n.Op))
 		o.AddChildren(t.trans(n.X), t.trans(n.Y))
 
 	case *ast.BlockStmt:
@@ -119,13 +391,13 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 		}
 
 	case *ast.BranchStmt:
-		o.Type = BranchStmt
+		o.Type = t.typ(BranchStmt, Keywords, int(n.Tok))
 		if n.Label != nil {
 			o.AddChildren(t.trans(n.Label))
 		}
 
 	case *ast.CallExpr:
-		o.Type = CallExpr
+		o.Type = t.typed(CallExpr, n)
 		o.AddChildren(t.trans(n.Fun))
 		for _, arg := range n.Args {
 			o.AddChildren(t.trans(arg))
@@ -141,7 +413,7 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 		}
 
 	case *ast.ChanType:
-		o.Type = ChanType
+		o.Type = t.typ(ChanType, Keywords, int(n.Dir))
 		o.AddChildren(t.trans(n.Value))
 
 	case *ast.CommClause:
@@ -198,6 +470,8 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 
 	case *ast.File:
 		o.Type = File
+		t.packages.Store(t.filename, n.Name.Name)
+		t.decls.Store(t.filename, declRanges(t.fileset, n))
 		for _, decl := range n.Decls {
 			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
 				// skip import declarations
@@ -223,6 +497,13 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 		o.Type = FuncDecl
 		if n.Recv != nil {
 			o.AddChildren(t.trans(n.Recv))
+			if len(n.Recv.List) == 1 {
+				t.methods.Store(methodKey{t.filename, o.Pos}, Method{
+					Recv:      recvName(n.Recv.List[0].Type),
+					Name:      n.Name.Name,
+					Signature: signature(n.Type),
+				})
+			}
 		}
 		o.AddChildren(t.trans(n.Name), t.trans(n.Type))
 		if n.Body != nil {
@@ -244,7 +525,7 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 		}
 
 	case *ast.GenDecl:
-		o.Type = GenDecl
+		o.Type = t.typ(GenDecl, Keywords, int(n.Tok))
 		for _, spec := range n.Specs {
 			o.AddChildren(t.trans(spec))
 		}
@@ -254,7 +535,10 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 		o.AddChildren(t.trans(n.Call))
 
 	case *ast.Ident:
-		o.Type = Ident
+		o.Type = t.typed(Ident, n)
+		if t.template && n.Name == "_" {
+			o.Type = wildcard
+		}
 
 	case *ast.IfStmt:
 		o.Type = IfStmt
@@ -267,7 +551,7 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 		}
 
 	case *ast.IncDecStmt:
-		o.Type = IncDecStmt
+		o.Type = t.typ(IncDecStmt, Operators, int(n.Tok))
 		o.AddChildren(t.trans(n.X))
 
 	case *ast.IndexExpr:
@@ -383,7 +667,7 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 		o.AddChildren(t.trans(n.Assign), t.trans(n.Body))
 
 	case *ast.UnaryExpr:
-		o.Type = UnaryExpr
+		o.Type = t.typ(UnaryExpr, Operators, int(n.Op))
 		o.AddChildren(t.trans(n.X))
 
 	case *ast.ValueSpec:
@@ -403,5 +687,55 @@ func (t *transformer) trans(node ast.Node) (o *syntax.Node) {
 
 	}
 
+	o = t.normalize(o, node)
+	if t.suppress(o, node) {
+		return nil
+	}
 	return o
 }
+
+// recvName returns the name of the base type of the receiver type.
+func recvName(x ast.Expr) string {
+	for {
+		switch t := x.(type) {
+		case *ast.StarExpr:
+			x = t.X
+		case *ast.ParenExpr:
+			x = t.X
+		case *ast.IndexExpr:
+			x = t.X
+		case *ast.IndexListExpr:
+			x = t.X
+		default:
+			return types.ExprString(x)
+		}
+	}
+}
+
+// signature returns the types of the parameters and results of the
+// function type without their names.
+func signature(fn *ast.FuncType) string {
+	typeList := func(fields *ast.FieldList) []string {
+		var list []string
+		if fields == nil {
+			return nil
+		}
+		for _, f := range fields.List {
+			typ := types.ExprString(f.Type)
+			list = append(list, typ)
+			for i := 1; i < len(f.Names); i++ {
+				list = append(list, typ)
+			}
+		}
+		return list
+	}
+	sig := "(" + strings.Join(typeList(fn.Params), ", ") + ")"
+	switch results := typeList(fn.Results); len(results) {
+	case 0:
+	case 1:
+		sig += " " + results[0]
+	default:
+		sig += " (" + strings.Join(results, ", ") + ")"
+	}
+	return sig
+}
@@ -0,0 +1,205 @@
+package golang
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// writeFiles writes the files with the sources in a temporary directory
+// and returns their names.
+func writeFiles(t *testing.T, srcs map[string]string) map[string]string {
+	dir := t.TempDir()
+	names := make(map[string]string)
+	for name, src := range srcs {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(src), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		names[name] = filename
+	}
+	return names
+}
+
+// writeSrc writes the source to the file p.go in a temporary directory
+// and returns its name.
+func writeSrc(t *testing.T, src string) string {
+	return writeFiles(t, map[string]string{"p.go": src})["p.go"]
+}
+
+const methodSrc = `package p
+
+func f() {}
+
+func (s *Set[T]) Add(x T, keys ...string) {}
+
+func (l list) Len() (n int, err error) { return }
+`
+
+func TestMethod(t *testing.T) {
+	filename := writeSrc(t, methodSrc)
+	cfg := new(Config)
+	if _, err := cfg.Parse(filename); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		decl string
+		want Method
+		ok   bool
+	}{
+		{"func f", Method{}, false},
+		{"func (s", Method{"Set", "Add", "(T, ...string)"}, true},
+		{"func (l", Method{"list", "Len", "() (int, error)"}, true},
+	}
+	for _, tc := range testCases {
+		m, ok := cfg.Method(filename, strings.Index(methodSrc, tc.decl))
+		if m != tc.want || ok != tc.ok {
+			t.Errorf("%s: got %v, %v, want %v, %v", tc.decl, m, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+const enclosingSrc = `package p
+
+type (
+	A int
+	B struct{}
+)
+
+var x = func() int { return 1 }()
+
+func (s *Server) handle() {}
+
+func (l list[T]) Len() int { return 0 }
+
+func f() {}
+`
+
+func TestEnclosing(t *testing.T) {
+	filename := writeSrc(t, enclosingSrc)
+	parse := []struct {
+		name  string
+		parse func(c *Config) error
+	}{
+		{"Parse", func(c *Config) error {
+			_, err := c.Parse(filename)
+			return err
+		}},
+		{"ParsePackage", func(c *Config) error {
+			_, err := c.ParsePackage([]string{filename})
+			return err
+		}},
+	}
+	testCases := []struct {
+		at   string
+		want string
+	}{
+		{"package", ""},
+		{"B struct", "B"},
+		{"return 1", "x"},
+		{"func (s", "(*Server).handle"},
+		{"return 0", "list.Len"},
+		{"f() {}", "f"},
+	}
+	for _, p := range parse {
+		cfg := new(Config)
+		if err := p.parse(cfg); err != nil {
+			t.Fatal(err)
+		}
+		for _, tc := range testCases {
+			if got := cfg.Enclosing(filename, strings.Index(enclosingSrc, tc.at)); got != tc.want {
+				t.Errorf("%s: %s: got %q, want %q", p.name, tc.at, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestKind(t *testing.T) {
+	testCases := []struct {
+		typ  int
+		kind int
+	}{
+		{FuncDecl, FuncDecl},
+		{BinaryExpr | 12<<kindBits, BinaryExpr},
+		{-1, BadNode},
+		{-2 - 233, BadNode}, // the end of the 234th file, FuncDecl in the low bits
+	}
+	for _, tc := range testCases {
+		if got := Kind(tc.typ); got != tc.kind {
+			t.Errorf("Kind(%d) = %d, want %d", tc.typ, got, tc.kind)
+		}
+	}
+}
+
+func TestLevel(t *testing.T) {
+	testCases := []struct {
+		a, b  string
+		level int // the lowest level distinguishing a and b
+	}{
+		{"_ = a < b", "_ = a + b", Operators},
+		{"a += b", "a = b", Operators},
+		{"a++", "a--", Operators},
+		{"_ = -a", "_ = !a", Operators},
+		{"for {\n\tbreak\n}", "for {\n\tcontinue\n}", Keywords},
+		{"_ = 1", "_ = 'x'", Keywords},
+		{"_ = a + b", "_ = b + a", -1},
+	}
+	for _, tc := range testCases {
+		for _, level := range []int{Kinds, Operators, Keywords} {
+			cfg := &Config{Level: level}
+			equal := equalTypes(parseTypes(t, cfg, tc.a), parseTypes(t, cfg, tc.b))
+			if distinguished := tc.level >= 0 && level >= tc.level; equal == distinguished {
+				t.Errorf("%q and %q at level %d: got equal %v, want %v", tc.a, tc.b, level, equal, !distinguished)
+			}
+		}
+	}
+}
+
+func TestIsStmt(t *testing.T) {
+	testCases := []struct {
+		kind int
+		stmt bool
+	}{
+		{AssignStmt, true},
+		{IfStmt, true},
+		{ReturnStmt, true},
+		{BlockStmt, false}, // blocks are counted by their statements
+		{LabeledStmt, false},
+		{EmptyStmt, false},
+		{CallExpr, false},
+		{IfStmt | 3<<kindBits, true},
+	}
+	for _, tc := range testCases {
+		if got := IsStmt(&syntax.Node{Type: tc.kind}); got != tc.stmt {
+			t.Errorf("IsStmt(%s) = %v, want %v", kindNames[Kind(tc.kind)], got, tc.stmt)
+		}
+	}
+}
+
+func TestSetCorpus(t *testing.T) {
+	src := "package p\n\nvar x = 1\n"
+	files := writeFiles(t, map[string]string{"a.go": src, "b.go": src})
+	cfg := new(Config)
+	for i, name := range []string{"a.go", "b.go"} {
+		filename := files[name]
+		if i > 0 {
+			cfg.SetCorpus(filename, i)
+		}
+		root, err := cfg.Parse(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range syntax.Serialize(root) {
+			if n.Corpus != i {
+				t.Errorf("%s: got corpus %d, want %d", name, n.Corpus, i)
+				break
+			}
+		}
+	}
+}
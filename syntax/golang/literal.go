@@ -0,0 +1,31 @@
+package golang
+
+import (
+	"go/constant"
+	"go/token"
+	"strconv"
+	"unicode/utf8"
+)
+
+// LiteralValue returns the value of the string or numeric literal
+// prefixed by its kind, which is the same for literals such as 0x10
+// and 16, and the length of the literal in characters without quotes.
+// It reports false for the other literals.
+func LiteralValue(lit string) (value string, length int, ok bool) {
+	switch {
+	case lit == "" || lit[0] == '\'':
+		return "", 0, false
+	case lit[0] == '"' || lit[0] == '`':
+		s, err := strconv.Unquote(lit)
+		if err != nil {
+			return "", 0, false
+		}
+		return "string " + s, utf8.RuneCountInString(s), true
+	}
+	for _, kind := range []token.Token{token.INT, token.FLOAT, token.IMAG} {
+		if v := constant.MakeFromLiteral(lit, kind, 0); v.Kind() != constant.Unknown {
+			return kind.String() + " " + v.ExactString(), len(lit), true
+		}
+	}
+	return "", 0, false
+}
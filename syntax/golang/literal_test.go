@@ -0,0 +1,28 @@
+package golang
+
+import "testing"
+
+func TestLiteralValue(t *testing.T) {
+	testCases := []struct {
+		lit    string
+		value  string
+		length int
+		ok     bool
+	}{
+		{`"select *"`, "string select *", 8, true},
+		{"`select *`", "string select *", 8, true},
+		{`"žluť"`, "string žluť", 4, true},
+		{"3600", "INT 3600", 4, true},
+		{"0xe10", "INT 3600", 5, true},
+		{"1_000.5", "FLOAT 2001/2", 7, true},
+		{"2i", "IMAG (0 + 2i)", 2, true},
+		{"'a'", "", 0, false},
+	}
+	for _, tc := range testCases {
+		value, length, ok := LiteralValue(tc.lit)
+		if value != tc.value || length != tc.length || ok != tc.ok {
+			t.Errorf("for %s, got %q, %d, %v, want %q, %d, %v", tc.lit, value, length, ok,
+				tc.value, tc.length, tc.ok)
+		}
+	}
+}
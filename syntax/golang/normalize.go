@@ -0,0 +1,241 @@
+package golang
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// Normalization rules applied to the syntax tree.
+const (
+	// NoParens drops parenthesized expressions.
+	NoParens = 1 << iota
+	// NoQualifiers treats qualified identifiers pkg.Name as Name.
+	NoQualifiers
+	// NoConversions treats type conversions as their operands.
+	NoConversions
+	// RangeLoops treats loops of the form for i := 0; i < n; i++
+	// as range loops of the form for i := range n.
+	RangeLoops
+)
+
+// Normalizations maps names of the normalization rules to the rules.
+var Normalizations = map[string]int{
+	"parens":      NoParens,
+	"qualifiers":  NoQualifiers,
+	"conversions": NoConversions,
+	"loops":       RangeLoops,
+}
+
+// rules is the normalization pipeline. The enabled rules are applied
+// in order to each transformed node.
+var rules = []struct {
+	rule  int
+	apply func(t *transformer, o *syntax.Node, n ast.Node) *syntax.Node
+}{
+	{NoParens, (*transformer).dropParens},
+	{NoQualifiers, (*transformer).dropQualifier},
+	{NoConversions, (*transformer).dropConversion},
+	{RangeLoops, (*transformer).rangeLoop},
+}
+
+// normalize applies the enabled normalization rules to the node o
+// transformed from n.
+func (t *transformer) normalize(o *syntax.Node, n ast.Node) *syntax.Node {
+	for _, r := range rules {
+		if t.Normalize&r.rule != 0 {
+			o = r.apply(t, o, n)
+		}
+	}
+	return o
+}
+
+func (t *transformer) dropParens(o *syntax.Node, n ast.Node) *syntax.Node {
+	if _, ok := n.(*ast.ParenExpr); ok && len(o.Children) == 1 {
+		return o.Children[0]
+	}
+	return o
+}
+
+func (t *transformer) dropQualifier(o *syntax.Node, n ast.Node) *syntax.Node {
+	sel, ok := n.(*ast.SelectorExpr)
+	if !ok || len(o.Children) != 2 {
+		return o
+	}
+	id, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return o
+	}
+	if t.info != nil {
+		if _, ok := t.info.Uses[id].(*types.PkgName); ok {
+			return o.Children[1]
+		}
+		return o
+	}
+	if id.Obj == nil && t.imports[id.Name] {
+		return o.Children[1]
+	}
+	return o
+}
+
+func (t *transformer) dropConversion(o *syntax.Node, n ast.Node) *syntax.Node {
+	call, ok := n.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 || call.Ellipsis.IsValid() || len(o.Children) != 2 {
+		return o
+	}
+	if t.info != nil {
+		if !t.info.Types[call.Fun].IsType() {
+			return o
+		}
+	} else if !isType(call.Fun) {
+		return o
+	}
+	return o.Children[1]
+}
+
+func (t *transformer) rangeLoop(o *syntax.Node, n ast.Node) *syntax.Node {
+	loop, ok := n.(*ast.ForStmt)
+	if !ok || len(o.Children) != 4 || len(o.Children[0].Children) != 2 ||
+		len(o.Children[1].Children) != 2 {
+		// some of the parts may have been suppressed
+		return o
+	}
+	init, ok := loop.Init.(*ast.AssignStmt)
+	if !ok || init.Tok != token.DEFINE || len(init.Lhs) != 1 || len(init.Rhs) != 1 {
+		return o
+	}
+	if lit, ok := init.Rhs[0].(*ast.BasicLit); !ok || lit.Value != "0" {
+		return o
+	}
+	key, ok := init.Lhs[0].(*ast.Ident)
+	if !ok {
+		return o
+	}
+	cond, ok := loop.Cond.(*ast.BinaryExpr)
+	if !ok || cond.Op != token.LSS || !isIdent(cond.X, key.Name) {
+		return o
+	}
+	post, ok := loop.Post.(*ast.IncDecStmt)
+	if !ok || post.Tok != token.INC || !isIdent(post.X, key.Name) {
+		return o
+	}
+	// the range is evaluated only once and modifying the variable
+	// in the body does not affect the iterations
+	if modifies(loop.Body, key.Name) {
+		return o
+	}
+	if bound, ok := cond.Y.(*ast.Ident); ok && modifies(loop.Body, bound.Name) {
+		return o
+	}
+
+	// the children are the init, cond, post statements and the body
+	r := syntax.NewNode()
+	r.Type = RangeStmt
+	r.Filename, r.Pos, r.End = o.Filename, o.Pos, o.End
+	r.AddChildren(o.Children[0].Children[1], o.Children[1].Children[1], o.Children[3])
+	return r
+}
+
+// modifies reports whether the variable of the name may be modified
+// in the body.
+func modifies(body *ast.BlockStmt, name string) bool {
+	var found bool
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, x := range n.Lhs {
+				found = found || isIdent(x, name)
+			}
+		case *ast.IncDecStmt:
+			found = found || isIdent(n.X, name)
+		case *ast.UnaryExpr:
+			found = found || n.Op == token.AND && isIdent(n.X, name)
+		}
+		return !found
+	})
+	return found
+}
+
+func isIdent(x ast.Expr, name string) bool {
+	id, ok := x.(*ast.Ident)
+	return ok && id.Name == name
+}
+
+// predeclaredTypes are the names of the predeclared types that can be used
+// in type conversions.
+var predeclaredTypes = map[string]bool{
+	"bool": true, "byte": true, "complex64": true, "complex128": true,
+	"float32": true, "float64": true, "int": true, "int8": true,
+	"int16": true, "int32": true, "int64": true, "rune": true,
+	"string": true, "uint": true, "uint8": true, "uint16": true,
+	"uint32": true, "uint64": true, "uintptr": true,
+}
+
+// isType reports whether x is syntactically known to be a type.
+func isType(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.ArrayType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType,
+		*ast.MapType, *ast.StructType:
+		return true
+	case *ast.ParenExpr:
+		if star, ok := x.X.(*ast.StarExpr); ok {
+			return isType(star.X) || isNamed(star.X)
+		}
+		return isType(x.X)
+	case *ast.Ident:
+		return x.Obj == nil && predeclaredTypes[x.Name]
+	}
+	return false
+}
+
+// isNamed reports whether x is a possibly qualified identifier.
+func isNamed(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.Ident:
+		return true
+	case *ast.SelectorExpr:
+		_, ok := x.X.(*ast.Ident)
+		return ok
+	}
+	return false
+}
+
+// importNames returns the names under which the packages are imported
+// in the file.
+func importNames(file *ast.File) map[string]bool {
+	names := make(map[string]bool)
+	for _, spec := range file.Imports {
+		if spec.Name != nil {
+			names[spec.Name.Name] = true
+			continue
+		}
+		p, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path.Base(p)
+		if isMajorVersion(name) && path.Dir(p) != "." {
+			name = path.Base(path.Dir(p))
+		}
+		if i := strings.IndexByte(name, '.'); i > 0 {
+			name = name[:i]
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// isMajorVersion reports whether the import path element is a major
+// version suffix such as v2.
+func isMajorVersion(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(elem[1:])
+	return err == nil
+}
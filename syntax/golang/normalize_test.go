@@ -0,0 +1,74 @@
+package golang
+
+import (
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// parseTypes returns the types of the serialized nodes of a file
+// importing strings with a function of the body.
+func parseTypes(t *testing.T, cfg *Config, body string) []int {
+	src := "package p\n\nimport \"strings\"\n\nfunc f(a, b, n int, s string) {\n" + body + "\n}\n"
+	filename := writeSrc(t, src)
+	root, err := cfg.Parse(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []int
+	for _, n := range syntax.Serialize(root) {
+		types = append(types, n.Type)
+	}
+	return types
+}
+
+func equalTypes(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNormalize(t *testing.T) {
+	testCases := []struct {
+		rule       int
+		body       string
+		normalized string // the equivalent body with the rule applied
+		equal      bool
+	}{
+		{NoParens, "a = (a + b)", "a = a + b", true},
+		{NoParens, "a = -(a)", "a = -a", true},
+		{NoQualifiers, "s = strings.TrimSpace(s)", "s = TrimSpace(s)", true},
+		{NoQualifiers, "s = s.x", "s = x", false}, // not a package
+		{NoConversions, "a = int(b)", "a = b", true},
+		{NoConversions, "s = string([]byte(s))", "s = s", true},
+		{NoConversions, "a = f(b)", "a = b", false}, // not a type
+		{RangeLoops, "for i := 0; i < n; i++ {\n\ta += i\n}", "for i := range n {\n\ta += i\n}", true},
+		{RangeLoops, "for i := 0; i < len(s); i++ {\n\ta += i\n}", "for i := range len(s) {\n\ta += i\n}", true},
+		{RangeLoops, "for i := 0; i < n; i += 2 {\n\ta += i\n}", "for i := range n {\n\ta += i\n}", false},
+		{RangeLoops, "for i := 1; i < n; i++ {\n\ta += i\n}", "for i := range n {\n\ta += i\n}", false},
+		{RangeLoops, "for i := 0; i <= n; i++ {\n\ta += i\n}", "for i := range n {\n\ta += i\n}", false},
+		{RangeLoops, "for i := 0; i < n; i++ {\n\ti++\n}", "for i := range n {\n\ti++\n}", false},
+		{RangeLoops, "for i := 0; i < n; i++ {\n\tn--\n}", "for i := range n {\n\tn--\n}", false},
+		{RangeLoops, "for i := 0; i < n; i++ {\n\tp := &i\n\t_ = p\n}", "for i := range n {\n\tp := &i\n\t_ = p\n}", false},
+	}
+	for _, tc := range testCases {
+		cfg := &Config{Normalize: tc.rule}
+		got := parseTypes(t, cfg, tc.body)
+		want := parseTypes(t, cfg, tc.normalized)
+		if equalTypes(got, want) != tc.equal {
+			t.Errorf("%q and %q: got equal %v, want %v", tc.body, tc.normalized, !tc.equal, tc.equal)
+		}
+		if tc.equal && equalTypes(parseTypes(t, new(Config), tc.body), parseTypes(t, new(Config), tc.normalized)) {
+			t.Errorf("%q and %q: equal without the rule", tc.body, tc.normalized)
+		}
+		if !tc.equal && !equalTypes(got, parseTypes(t, new(Config), tc.body)) {
+			t.Errorf("%q: normalized although it must not be", tc.body)
+		}
+	}
+}
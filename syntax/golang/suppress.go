@@ -0,0 +1,169 @@
+package golang
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// A Suppressor reports whether the node o transformed from n should be
+// excluded, along with its subtree, from the search for clones.
+type Suppressor func(o *syntax.Node, n ast.Node) bool
+
+// Suppressors maps names of the built-in suppressors to them.
+var Suppressors = map[string]Suppressor{
+	"errchecks":  isErrCheck,
+	"testtables": isTestTable,
+	"maplits":    isMapLit,
+	"varblocks":  isVarBlock,
+}
+
+// SuppressKind returns a suppressor of the nodes of the given kind.
+func SuppressKind(kind int) Suppressor {
+	return func(o *syntax.Node, n ast.Node) bool {
+		return Kind(o.Type) == kind
+	}
+}
+
+// Suppressed returns the number of tokens suppressed so far.
+func (c *Config) Suppressed() int {
+	return int(c.suppressed.Load())
+}
+
+// suppress reports whether the node o should be suppressed. If so, the
+// suppressed tokens are counted.
+func (t *transformer) suppress(o *syntax.Node, n ast.Node) bool {
+	if _, ok := n.(*ast.File); ok {
+		return false
+	}
+	for _, s := range t.Suppress {
+		if s(o, n) {
+			t.suppressed.Add(int64(countNodes(o)))
+			return true
+		}
+	}
+	return false
+}
+
+func countNodes(n *syntax.Node) int {
+	cnt := 1
+	for _, child := range n.Children {
+		cnt += countNodes(child)
+	}
+	return cnt
+}
+
+// isErrCheck matches if err != nil { return ..., err }.
+func isErrCheck(o *syntax.Node, n ast.Node) bool {
+	stmt, ok := n.(*ast.IfStmt)
+	if !ok || stmt.Else != nil || len(stmt.Body.List) != 1 {
+		return false
+	}
+	cond, ok := stmt.Cond.(*ast.BinaryExpr)
+	if !ok || cond.Op != token.NEQ || !isIdent(cond.Y, "nil") {
+		return false
+	}
+	x, ok := cond.X.(*ast.Ident)
+	if !ok || !strings.HasSuffix(strings.ToLower(x.Name), "err") {
+		return false
+	}
+	_, ok = stmt.Body.List[0].(*ast.ReturnStmt)
+	return ok
+}
+
+// isTestTable matches slices of test cases in test files, that is, slice
+// literals of composite literals.
+func isTestTable(o *syntax.Node, n ast.Node) bool {
+	lit, ok := n.(*ast.CompositeLit)
+	if !ok || !strings.HasSuffix(o.Filename, "_test.go") || len(lit.Elts) == 0 {
+		return false
+	}
+	if typ, ok := lit.Type.(*ast.ArrayType); !ok || typ.Len != nil {
+		return false
+	}
+	for _, e := range lit.Elts {
+		if _, ok := e.(*ast.CompositeLit); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// isMapLit matches map literals.
+func isMapLit(o *syntax.Node, n ast.Node) bool {
+	lit, ok := n.(*ast.CompositeLit)
+	if !ok {
+		return false
+	}
+	_, ok = lit.Type.(*ast.MapType)
+	return ok
+}
+
+// isVarBlock matches parenthesized var declarations.
+func isVarBlock(o *syntax.Node, n ast.Node) bool {
+	decl, ok := n.(*ast.GenDecl)
+	return ok && decl.Tok == token.VAR && decl.Lparen.IsValid()
+}
+
+// wildcard is the type of the nodes matching any subtree in templates.
+const wildcard = 1<<kindBits - 1
+
+// SuppressTemplate returns a suppressor of the subtrees matching the Go
+// code snippet. The snippet is an expression, a declaration or a statement.
+// Identifiers _ in the snippet match any subtree.
+func (c *Config) SuppressTemplate(snippet string) (Suppressor, error) {
+	fset := token.NewFileSet()
+	var node ast.Node
+	if x, err := parser.ParseExprFrom(fset, "", snippet, 0); err == nil {
+		node = x
+	} else if f, err := parser.ParseFile(fset, "", "package p\n"+snippet, 0); err == nil && len(f.Decls) == 1 {
+		node = f.Decls[0]
+	} else if f, err := parser.ParseFile(fset, "", "package p; func _() {\n"+snippet+"\n}", 0); err == nil {
+		body := f.Decls[0].(*ast.FuncDecl).Body.List
+		if len(body) != 1 {
+			return nil, fmt.Errorf("template must be a single statement: %q", snippet)
+		}
+		node = body[0]
+	} else {
+		return nil, fmt.Errorf("invalid template %q", snippet)
+	}
+
+	t := &transformer{
+		// the template is not suppressed itself
+		Config:   &Config{Level: c.Level, Normalize: c.Normalize},
+		fileset:  fset,
+		template: true,
+	}
+	tmpl := t.trans(node)
+	return func(o *syntax.Node, n ast.Node) bool {
+		return matchTemplate(tmpl, o)
+	}, nil
+}
+
+// matchTemplate reports whether the tree o matches the template tree tmpl.
+// Nodes of the template without details match nodes of the same kind.
+func matchTemplate(tmpl, o *syntax.Node) bool {
+	switch {
+	case tmpl.Type == wildcard:
+		return true
+	case tmpl.Type == Kind(tmpl.Type):
+		if tmpl.Type != Kind(o.Type) {
+			return false
+		}
+	case tmpl.Type != o.Type:
+		return false
+	}
+	if len(tmpl.Children) != len(o.Children) {
+		return false
+	}
+	for i, child := range tmpl.Children {
+		if !matchTemplate(child, o.Children[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package golang
+
+import "testing"
+
+const suppressSrc = `package p
+
+func f() (int, error) {
+	x, err := g()
+	if err != nil {
+		return 0, err
+	}
+	m := map[string]int{"a": 1}
+	return x + m["a"], nil
+}
+`
+
+func TestSuppress(t *testing.T) {
+	filename := writeSrc(t, suppressSrc)
+	unsuppressed, err := new(Config).Parse(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := countNodes(unsuppressed)
+
+	tmpl, err := new(Config).SuppressTemplate("if _ != nil {\n\treturn _, err\n}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name       string
+		suppress   []Suppressor
+		suppressed int
+	}{
+		{"errchecks", []Suppressor{Suppressors["errchecks"]}, 8},
+		{"maplits", []Suppressor{Suppressors["maplits"]}, 7},
+		{"template", []Suppressor{tmpl}, 8},
+		{"kind", []Suppressor{SuppressKind(ReturnStmt)}, 10},
+	}
+	for _, tc := range testCases {
+		cfg := &Config{Suppress: tc.suppress}
+		n, err := cfg.Parse(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Suppressed() != tc.suppressed {
+			t.Errorf("%s: got %d suppressed tokens, want %d", tc.name, cfg.Suppressed(), tc.suppressed)
+		}
+		if cnt := countNodes(n); cnt+cfg.Suppressed() != total {
+			t.Errorf("%s: got %d tokens, want %d", tc.name, cnt, total-cfg.Suppressed())
+		}
+	}
+}
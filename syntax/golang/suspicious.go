@@ -0,0 +1,188 @@
+package golang
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// A Suspect is a group of identical fragments of a single construct,
+// likely a copy-paste bug.
+type Suspect struct {
+	// Desc describes the fragments, e.g. "identical case bodies".
+	Desc  string
+	Frags [][2]int // offsets of the starts and ends of the fragments
+}
+
+// Suspects returns the identical branches of if-else chains, the identical
+// bodies of cases of switch and select statements and the identical
+// operands of binary expressions in the source. The fragments are compared
+// by their tokens.
+func Suspects(src []byte) ([]Suspect, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	s := &suspects{fset: fset, src: src, elses: make(map[*ast.IfStmt]bool)}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt:
+			s.ifChain(n)
+		case *ast.SwitchStmt:
+			s.cases(n.Body, "")
+		case *ast.TypeSwitchStmt:
+			var bound string
+			if assign, ok := n.Assign.(*ast.AssignStmt); ok {
+				bound = assign.Lhs[0].(*ast.Ident).Name
+			}
+			s.cases(n.Body, bound)
+		case *ast.SelectStmt:
+			s.cases(n.Body, "")
+		case *ast.BinaryExpr:
+			s.operands(n)
+		}
+		return true
+	})
+	return s.found, nil
+}
+
+type suspects struct {
+	fset  *token.FileSet
+	src   []byte
+	elses map[*ast.IfStmt]bool // the if statements chained to others
+	found []Suspect
+}
+
+func (s *suspects) offset(pos token.Pos) int {
+	return s.fset.Position(pos).Offset
+}
+
+// text returns the tokens of the source from pos to end.
+func (s *suspects) text(pos, end token.Pos) string {
+	return strings.Join(Tokens(s.src[s.offset(pos):s.offset(end)]), " ")
+}
+
+// group adds the groups of the fragments of the same text.
+func (s *suspects) group(desc string, frags [][2]token.Pos) {
+	var texts []string
+	groups := make(map[string][][2]int)
+	for _, frag := range frags {
+		text := s.text(frag[0], frag[1])
+		if _, ok := groups[text]; !ok {
+			texts = append(texts, text)
+		}
+		groups[text] = append(groups[text], [2]int{s.offset(frag[0]), s.offset(frag[1])})
+	}
+	for _, text := range texts {
+		if len(groups[text]) > 1 {
+			s.found = append(s.found, Suspect{desc, groups[text]})
+		}
+	}
+}
+
+// ifChain finds the identical branches of the if-else chain starting
+// with the if statement.
+func (s *suspects) ifChain(n *ast.IfStmt) {
+	if s.elses[n] {
+		return
+	}
+	var bodies [][2]token.Pos
+	for {
+		if len(n.Body.List) > 0 {
+			bodies = append(bodies, [2]token.Pos{n.Body.Pos(), n.Body.End()})
+		}
+		switch els := n.Else.(type) {
+		case *ast.IfStmt:
+			s.elses[els] = true
+			n = els
+			continue
+		case *ast.BlockStmt:
+			if len(els.List) > 0 {
+				bodies = append(bodies, [2]token.Pos{els.Pos(), els.End()})
+			}
+		}
+		break
+	}
+	s.group("identical if-else branches", bodies)
+}
+
+// cases finds the identical bodies of the case clauses of the switch
+// or select statement. The bodies referring to the variable bound by
+// a type switch are skipped as the variable has a different type in
+// each clause.
+func (s *suspects) cases(body *ast.BlockStmt, bound string) {
+	var bodies [][2]token.Pos
+	for _, clause := range body.List {
+		var stmts []ast.Stmt
+		switch c := clause.(type) {
+		case *ast.CaseClause:
+			stmts = c.Body
+		case *ast.CommClause:
+			stmts = c.Body
+		}
+		if len(stmts) == 0 || refers(stmts, bound) {
+			continue
+		}
+		if br, ok := stmts[len(stmts)-1].(*ast.BranchStmt); ok && br.Tok == token.FALLTHROUGH {
+			continue
+		}
+		bodies = append(bodies, [2]token.Pos{stmts[0].Pos(), stmts[len(stmts)-1].End()})
+	}
+	s.group("identical case bodies", bodies)
+}
+
+func refers(stmts []ast.Stmt, name string) bool {
+	if name == "" {
+		return false
+	}
+	var found bool
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == name {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// suspiciousOps are the operators whose identical operands are likely
+// a bug.
+var suspiciousOps = map[token.Token]bool{
+	token.LAND: true, token.LOR: true,
+	token.EQL: true, token.NEQ: true,
+	token.LSS: true, token.LEQ: true, token.GTR: true, token.GEQ: true,
+	token.SUB: true, token.QUO: true, token.REM: true,
+	token.AND: true, token.OR: true, token.XOR: true, token.AND_NOT: true,
+}
+
+// operands finds the identical operands of the binary expression.
+// The operands calling functions or receiving from channels may differ
+// and x != x and x == x on variables are the test for NaN.
+func (s *suspects) operands(n *ast.BinaryExpr) {
+	if !suspiciousOps[n.Op] || hasEffects(n.X) {
+		return
+	}
+	if _, ok := n.X.(*ast.Ident); ok && (n.Op == token.EQL || n.Op == token.NEQ) {
+		return
+	}
+	s.group("identical operands of "+n.Op.String(),
+		[][2]token.Pos{{n.X.Pos(), n.X.End()}, {n.Y.Pos(), n.Y.End()}})
+}
+
+func hasEffects(x ast.Expr) bool {
+	var found bool
+	ast.Inspect(x, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			found = true
+		case *ast.UnaryExpr:
+			found = n.Op == token.ARROW
+		}
+		return !found
+	})
+	return found
+}
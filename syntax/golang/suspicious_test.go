@@ -0,0 +1,60 @@
+package golang
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const suspectsSrc = `package p
+
+func f(x, y int, v interface{}, ch chan int) {
+	if x > 0 {
+		g(x)
+	} else if x < 0 {
+		g(y)
+	} else {
+		g(x) // the same
+	}
+	switch x {
+	case 1:
+		g(y)
+	case 2:
+		g(x)
+		fallthrough
+	case 3:
+		g(y)
+	}
+	switch v := v.(type) {
+	case int:
+		g(v)
+	case string:
+		g(v)
+	}
+	if x == y && x == y || x != x || g(x) == g(x) || <-ch == <-ch {
+	}
+}
+`
+
+func TestSuspects(t *testing.T) {
+	suspects, err := Suspects([]byte(suspectsSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual [][]string
+	for _, s := range suspects {
+		frags := []string{s.Desc}
+		for _, frag := range s.Frags {
+			frags = append(frags, strings.TrimSpace(suspectsSrc[frag[0]:frag[1]]))
+		}
+		actual = append(actual, frags)
+	}
+	expect := [][]string{
+		{"identical if-else branches", "{\n\t\tg(x)\n\t}", "{\n\t\tg(x) // the same\n\t}"},
+		{"identical case bodies", "g(y)", "g(y)"},
+		{"identical operands of &&", "x == y", "x == y"},
+	}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("got %q, want %q", actual, expect)
+	}
+}
@@ -0,0 +1,124 @@
+package golang
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// SuggestTable returns a table-driven test that could replace the test
+// functions of the fragments. The identifiers and literals differing in
+// the functions become fields of the test cases and the body of the first
+// function is run as a subtest of each case. The files of the fragments
+// are type-checked.
+func (c *Config) SuggestTable(frags [][]*syntax.Node) (string, bool) {
+	bodies := make([][]*syntax.Node, len(frags))
+	funcs := make([]*ast.FuncDecl, len(frags))
+	for i, frag := range frags {
+		filename := frag[0].Filename
+		if len(frag) != 1 || Kind(frag[0].Type) != FuncDecl || !strings.HasSuffix(filename, "_test.go") {
+			return "", false
+		}
+		_, file := c.checkPackage(filename)
+		if file == nil {
+			return "", false
+		}
+		tf := c.types.fset.File(file.Pos())
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && tf.Offset(fn.Pos()) == frag[0].Pos {
+				funcs[i] = fn
+			}
+		}
+		fn := funcs[i]
+		if fn == nil || testParam(fn) == "" || len(fn.Body.List) == 0 {
+			return "", false
+		}
+		for _, stmt := range fn.Body.List {
+			bodies[i] = append(bodies[i], &syntax.Node{Filename: filename,
+				Pos: tf.Offset(stmt.Pos()), End: tf.Offset(stmt.End())})
+		}
+	}
+
+	x, ok := c.align(bodies, false)
+	if !ok || len(x.paramList) == 0 {
+		return "", false
+	}
+	for _, it := range x.insts[0].items {
+		if id, ok := it.node.(*ast.Ident); ok && (id.Name == "tc" || id.Name == "testCases") {
+			return "", false
+		}
+	}
+
+	name, cases := tableNames(funcs)
+	t := testParam(funcs[0])
+	qual := x.qualifier()
+	names := x.paramNames(map[string]bool{"name": true})
+	fields := make(map[*extractParam]string)
+	var b strings.Builder
+	fmt.Fprintf(&b, "func %s(%s *testing.T) {\ntestCases := []struct {\nname string\n", name, t)
+	for _, p := range x.paramList {
+		fmt.Fprintf(&b, "%s %s\n", names[p], types.TypeString(p.typ, qual))
+		fields[p] = "tc." + names[p]
+	}
+	b.WriteString("}{\n")
+	for k := range x.insts {
+		b.WriteString("{" + strconv.Quote(cases[k]))
+		for _, p := range x.paramList {
+			b.WriteString(", " + p.texts[k])
+		}
+		b.WriteString("},\n")
+	}
+	fmt.Fprintf(&b, "}\nfor _, tc := range testCases {\n%s.Run(tc.name, func(%s *testing.T) {\n", t, t)
+	b.WriteString(x.body(fields))
+	b.WriteString("\n})\n}\n}\n")
+
+	const pkg = "package p\n\n"
+	src, err := format.Source([]byte(pkg + b.String()))
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(src[len(pkg):])), true
+}
+
+// testParam returns the name of the parameter of the test function,
+// or an empty string if fn is not a test function.
+func testParam(fn *ast.FuncDecl) string {
+	params := fn.Type.Params.List
+	if fn.Recv != nil || !strings.HasPrefix(fn.Name.Name, "Test") || fn.Type.Results != nil ||
+		len(params) != 1 || len(params[0].Names) != 1 || types.ExprString(params[0].Type) != "*testing.T" {
+		return ""
+	}
+	return params[0].Names[0].Name
+}
+
+// tableNames returns the name of the table-driven test replacing the test
+// functions, which is their common prefix, and the names of the cases,
+// which are the rest of their names.
+func tableNames(funcs []*ast.FuncDecl) (string, []string) {
+	prefix := funcs[0].Name.Name
+	for _, fn := range funcs[1:] {
+		i := 0
+		for i < len(prefix) && i < len(fn.Name.Name) && prefix[i] == fn.Name.Name[i] {
+			i++
+		}
+		prefix = prefix[:i]
+	}
+	name := strings.TrimRight(prefix, "_")
+	if len(name) <= len("Test") {
+		name = funcs[0].Name.Name
+	}
+	var cases []string
+	for _, fn := range funcs {
+		c := fn.Name.Name[len(prefix):]
+		if c == "" {
+			c = fn.Name.Name
+		}
+		cases = append(cases, c)
+	}
+	return name, cases
+}
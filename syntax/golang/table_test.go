@@ -0,0 +1,72 @@
+package golang
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+const tableSrc = `package p
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestParseSmall(t *testing.T) {
+	n, err := strconv.Atoi("42")
+	if err != nil || n != 42 {
+		t.Errorf("got %d, %v", n, err)
+	}
+}
+
+func TestParseLarge(t *testing.T) {
+	n, err := strconv.Atoi("123456")
+	if err != nil || n != 123456 {
+		t.Errorf("got %d, %v", n, err)
+	}
+}
+`
+
+const tableExpect = `func TestParse(t *testing.T) {
+	testCases := []struct {
+		name string
+		s    string
+		n    int
+	}{
+		{"Small", "42", 42},
+		{"Large", "123456", 123456},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n, err := strconv.Atoi(tc.s)
+			if err != nil || n != tc.n {
+				t.Errorf("got %d, %v", n, err)
+			}
+		})
+	}
+}`
+
+func TestSuggestTable(t *testing.T) {
+	filename := writeFiles(t, map[string]string{"p_test.go": tableSrc})["p_test.go"]
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, tableSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var frags [][]*syntax.Node
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			frags = append(frags, []*syntax.Node{{Type: FuncDecl, Filename: filename,
+				Pos: fset.Position(fn.Pos()).Offset, End: fset.Position(fn.End()).Offset}})
+		}
+	}
+
+	actual, ok := new(Config).SuggestTable(frags)
+	if !ok || actual != tableExpect {
+		t.Errorf("got\n%s\nwant\n%s", actual, tableExpect)
+	}
+}
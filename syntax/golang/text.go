@@ -0,0 +1,165 @@
+package golang
+
+import (
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// Tokens splits the Go source code fragment to tokens. Identifiers and
+// literals are represented by their text, other tokens by their string
+// representation. Comments and automatically inserted semicolons are
+// skipped.
+func Tokens(src []byte) []string {
+	var toks []string
+	for _, t := range scanTokens(src) {
+		if t.lit != "" {
+			toks = append(toks, t.lit)
+		} else {
+			toks = append(toks, t.tok.String())
+		}
+	}
+	return toks
+}
+
+// TextSimilarity returns the token-level edit similarity of the token
+// sequences a and b. It is 1 for identical sequences.
+func TextSimilarity(a, b []string) float64 {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	if n == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(a, b))/float64(n)
+}
+
+// editDistance returns the Levenshtein distance of the token sequences.
+func editDistance(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := range a {
+		cur[0] = i + 1
+		for j := range b {
+			d := prev[j]
+			if a[i] != b[j] {
+				d++
+			}
+			if prev[j+1]+1 < d {
+				d = prev[j+1] + 1
+			}
+			if cur[j]+1 < d {
+				d = cur[j] + 1
+			}
+			cur[j+1] = d
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// An Unrenamed is an occurrence of an identifier in a clone that is not
+// renamed although its other occurrences are.
+type Unrenamed struct {
+	Offset   int // offset in the clone
+	Name     string
+	Expected string
+}
+
+// FindUnrenamed compares the identifiers of the clones a and b and returns
+// the occurrences of identifiers in b that are the same as in a while
+// most of their other occurrences are renamed to the same name, likely
+// a copy-paste bug. The clones must have the same tokens except for
+// identifiers and literals. The names of fields and methods are qualified
+// by the identifiers they are selected from, e.g. n.left.
+func FindUnrenamed(a, b []byte) []Unrenamed {
+	ta, tb := scanTokens(a), scanTokens(b)
+	if len(ta) != len(tb) {
+		return nil
+	}
+	for i, t := range ta {
+		if t.tok != tb[i].tok {
+			return nil
+		}
+	}
+	na, nb := qualifiedNames(ta), qualifiedNames(tb)
+	renames := make(map[string]map[string]int) // by the names in a
+	for i, name := range na {
+		if name != "" {
+			if renames[name] == nil {
+				renames[name] = make(map[string]int)
+			}
+			renames[name][nb[i]]++
+		}
+	}
+
+	var found []Unrenamed
+	for i, name := range na {
+		if name == "" || nb[i] != name {
+			continue
+		}
+		// the most frequent new name
+		var expected string
+		var cnt int
+		for name, n := range renames[name] {
+			if n > cnt || n == cnt && name < expected {
+				expected, cnt = name, n
+			}
+		}
+		if expected != name && cnt >= 2 && cnt > renames[name][name] {
+			expected = expected[strings.LastIndexByte(expected, '.')+1:]
+			found = append(found, Unrenamed{tb[i].offset, tb[i].lit, expected})
+		}
+	}
+	return found
+}
+
+// qualifiedNames returns the names of the identifiers among the tokens,
+// or empty strings for the other tokens. The names selected from
+// identifiers are qualified by the first identifiers of the selectors.
+func qualifiedNames(toks []scannedToken) []string {
+	names := make([]string, len(toks))
+	var base string
+	for i, t := range toks {
+		if t.tok != token.IDENT {
+			continue
+		}
+		if i > 1 && toks[i-1].tok == token.PERIOD && toks[i-2].tok == token.IDENT {
+			names[i] = base + "." + t.lit
+		} else {
+			names[i], base = t.lit, t.lit
+		}
+	}
+	return names
+}
+
+type scannedToken struct {
+	tok    token.Token
+	lit    string
+	offset int
+}
+
+// scanTokens scans the tokens of the Go source code fragment skipping
+// comments and automatically inserted semicolons.
+func scanTokens(src []byte) []scannedToken {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, 0)
+
+	var toks []scannedToken
+	for {
+		pos, tok, lit := s.Scan()
+		switch {
+		case tok == token.EOF:
+			return toks
+		case tok == token.SEMICOLON && lit == "\n":
+			continue
+		}
+		toks = append(toks, scannedToken{tok, lit, file.Offset(pos)})
+	}
+}
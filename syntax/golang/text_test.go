@@ -0,0 +1,68 @@
+package golang
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	testCases := []struct {
+		src    string
+		expect string
+	}{
+		{"a := b + 1", "a := b + 1"},
+		{"if x == nil {\n\treturn err // comment\n}", "if x == nil { return err }"},
+		{`f("str", 'c', 2.5)`, `f ( "str" , 'c' , 2.5 )`},
+	}
+
+	for _, tc := range testCases {
+		actual := strings.Join(Tokens([]byte(tc.src)), " ")
+		if actual != tc.expect {
+			t.Errorf("got '%s', want '%s'", actual, tc.expect)
+		}
+	}
+}
+
+func TestTextSimilarity(t *testing.T) {
+	testCases := []struct {
+		a, b   string
+		expect float64
+	}{
+		{"a := b + 1", "a := b + 1", 1},
+		{"a := b + 1", "a := c + 1", 0.8},
+		{"a := b + 1", "a := b", 0.6},
+		{"a", "", 0},
+	}
+
+	for _, tc := range testCases {
+		actual := TextSimilarity(Tokens([]byte(tc.a)), Tokens([]byte(tc.b)))
+		if actual != tc.expect {
+			t.Errorf("for '%s' and '%s', got %v, want %v", tc.a, tc.b, actual, tc.expect)
+		}
+	}
+}
+
+func TestFindUnrenamed(t *testing.T) {
+	testCases := []struct {
+		a, b   string
+		expect []Unrenamed
+	}{
+		{
+			"x := left.v + left.w\nf(left)",
+			"x := right.v + left.w\nf(right)",
+			[]Unrenamed{{15, "left", "right"}},
+		},
+		{"x := left.v + left.w", "x := right.v + left.w", nil},
+		{"f(left, left)", "f(right, right)", nil},
+		{"f(left, left, left)", "g(right, right, left + 1)", nil},
+		{"o.Type = A\nf(n.Type, n.Type)", "o.Type = B\nf(n.Comm, n.Comm)", nil},
+	}
+
+	for _, tc := range testCases {
+		actual := FindUnrenamed([]byte(tc.a), []byte(tc.b))
+		if !reflect.DeepEqual(actual, tc.expect) {
+			t.Errorf("for '%s' and '%s', got %v, want %v", tc.a, tc.b, actual, tc.expect)
+		}
+	}
+}
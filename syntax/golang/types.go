@@ -0,0 +1,187 @@
+package golang
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// typeInfo holds the state shared by type-checked packages.
+type typeInfo struct {
+	once     sync.Once
+	fset     *token.FileSet
+	importer types.Importer
+
+	mu      sync.Mutex
+	descs   map[string]int
+	checked map[string]*checkedPkg // by directories and package names, see SuggestExtract
+}
+
+// initTypes initializes the state shared by type-checked packages.
+func (c *Config) initTypes() {
+	c.types.fset = token.NewFileSet()
+	c.types.importer = importer.ForCompiler(c.types.fset, "source", nil)
+	c.types.descs = make(map[string]int)
+}
+
+// ParsePackage parses and type-checks the given files of a single directory
+// and returns their uniform syntax trees. Identifiers and calls are then
+// distinguished by their resolved objects and types. Files that cannot
+// be parsed are skipped and the first such error is returned along with
+// the trees of the other files.
+func (c *Config) ParsePackage(filenames []string) ([]*syntax.Node, error) {
+	c.types.once.Do(c.initTypes)
+	fset := c.types.fset
+
+	var firstErr error
+	var pkgNames []string
+	pkgs := make(map[string][]*ast.File)
+	for _, filename := range filenames {
+		file, err := parser.ParseFile(fset, filename, nil, 0)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		name := file.Name.Name
+		if _, ok := pkgs[name]; !ok {
+			pkgNames = append(pkgNames, name)
+		}
+		pkgs[name] = append(pkgs[name], file)
+	}
+
+	var nodes []*syntax.Node
+	for _, name := range pkgNames {
+		files := pkgs[name]
+		info := &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		}
+		conf := types.Config{
+			Importer: c.types.importer,
+			Error:    func(error) {}, // use whatever could be resolved
+		}
+		path := pkgPath(filepath.Dir(fset.File(files[0].Pos()).Name()), name)
+		pkg, _ := conf.Check(path, fset, files, info)
+		for _, file := range files {
+			filename := fset.File(file.Pos()).Name()
+			t := &transformer{
+				Config:   c,
+				fileset:  fset,
+				filename: filename,
+				corpus:   c.corpus(filename),
+				imports:  importNames(file),
+				info:     info,
+				pkg:      pkg,
+			}
+			nodes = append(nodes, t.trans(file))
+		}
+	}
+	return nodes, firstErr
+}
+
+// pkgPath returns the path of the package of the name in the directory,
+// which distinguishes packages of the same names in different directories.
+// External test packages have the _test suffix as with the go command.
+func pkgPath(dir, name string) string {
+	path := filepath.ToSlash(dir)
+	if strings.HasSuffix(name, "_test") {
+		path += "_test"
+	}
+	return path
+}
+
+// typed returns the node type of the given kind distinguished by the type
+// information of n, if it is available.
+func (t *transformer) typed(kind int, n ast.Node) int {
+	if t.info == nil {
+		return kind
+	}
+	var desc string
+	switch n := n.(type) {
+	case *ast.Ident:
+		desc = t.objectDesc(t.info.ObjectOf(n))
+	case *ast.CallExpr:
+		desc = t.callDesc(n)
+	}
+	if desc == "" {
+		return kind
+	}
+	return kind | t.descID(desc)<<kindBits
+}
+
+// descID returns a unique number of the type description.
+func (t *transformer) descID(desc string) int {
+	ti := &t.types
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	id, ok := ti.descs[desc]
+	if !ok {
+		id = len(ti.descs) + 1
+		ti.descs[desc] = id
+	}
+	return id
+}
+
+func (t *transformer) objectDesc(obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.Var:
+		if obj.IsField() {
+			return "field " + t.typeString(obj.Type())
+		}
+		return "var " + t.typeString(obj.Type())
+	case *types.Const:
+		return "const " + t.typeString(obj.Type())
+	case *types.TypeName:
+		return "type " + t.typeString(obj.Type())
+	case *types.Func:
+		return "func " + t.typeString(obj.Type())
+	case *types.PkgName:
+		return "package " + obj.Imported().Path()
+	case *types.Builtin:
+		return "builtin " + obj.Name()
+	case *types.Nil:
+		return "nil"
+	case *types.Label:
+		return "label"
+	}
+	return ""
+}
+
+func (t *transformer) callDesc(call *ast.CallExpr) string {
+	tv, ok := t.info.Types[call.Fun]
+	switch {
+	case !ok:
+		return ""
+	case tv.IsType():
+		return "conversion to " + t.typeString(tv.Type)
+	case tv.IsBuiltin():
+		return "call to builtin " + types.ExprString(call.Fun)
+	}
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+		if s := t.info.Selections[sel]; s != nil && s.Kind() == types.MethodVal {
+			return "call to method on " + t.typeString(s.Recv())
+		}
+	}
+	if sig, ok := tv.Type.Underlying().(*types.Signature); ok {
+		return "call to function returning " + t.typeString(sig.Results())
+	}
+	return ""
+}
+
+// typeString returns the string representation of typ. Types declared
+// in the checked package are not qualified so that clones copied across
+// packages along with their types still match.
+func (t *transformer) typeString(typ types.Type) string {
+	return types.TypeString(typ, types.RelativeTo(t.pkg))
+}
@@ -0,0 +1,91 @@
+package golang
+
+import (
+	"testing"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+const typesSrc = `package p
+
+import (
+	"bytes"
+	"strings"
+)
+
+func f() {
+	var b bytes.Buffer
+	var s strings.Builder
+	b.WriteString("x")
+	s.WriteString("x")
+	b.WriteString("x")
+}
+`
+
+func TestParsePackage(t *testing.T) {
+	filename := writeSrc(t, typesSrc)
+	// calls returns the types of the nodes of the calls
+	calls := func(root *syntax.Node) [][]int {
+		var calls [][]int
+		nodes := syntax.Serialize(root)
+		for i, n := range nodes {
+			if Kind(n.Type) != CallExpr {
+				continue
+			}
+			var types []int
+			for _, n := range nodes[i : i+n.Owns+1] {
+				types = append(types, n.Type)
+			}
+			calls = append(calls, types)
+		}
+		return calls
+	}
+
+	untyped, err := new(Config).Parse(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots, err := new(Config).ParsePackage([]string{filename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name  string
+		calls [][]int
+		// whether the call on strings.Builder differs from those
+		// on bytes.Buffer
+		differ bool
+	}{
+		{"untyped", calls(untyped), false},
+		{"typed", calls(roots[0]), true},
+	}
+	for _, tc := range testCases {
+		if len(tc.calls) != 3 {
+			t.Fatalf("%s: got %d calls, want 3", tc.name, len(tc.calls))
+		}
+		if !equalTypes(tc.calls[0], tc.calls[2]) {
+			t.Errorf("%s: the calls on bytes.Buffer differ", tc.name)
+		}
+		if differ := !equalTypes(tc.calls[0], tc.calls[1]); differ != tc.differ {
+			t.Errorf("%s: the calls on bytes.Buffer and strings.Builder differ: %v, want %v", tc.name, differ, tc.differ)
+		}
+	}
+}
+
+func TestPackagePaths(t *testing.T) {
+	src := "package p\n\ntype T int\n"
+	files := writeFiles(t, map[string]string{"a/p.go": src, "b/p.go": src})
+	cfg := new(Config)
+	var paths []string
+	for _, name := range []string{"a/p.go", "b/p.go"} {
+		filename := files[name]
+		p, _ := cfg.checkPackage(filename)
+		if p == nil || p.err != nil {
+			t.Fatalf("%s: package not checked", filename)
+		}
+		paths = append(paths, p.pkg.Path())
+	}
+	if paths[0] == paths[1] {
+		t.Errorf("the packages p in different directories have the same path %q", paths[0])
+	}
+}
@@ -0,0 +1,66 @@
+package golang
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mibk/dupl/syntax"
+)
+
+// ParseWeights parses the weights of node kinds. The weights are given
+// as Kind=weight pairs, e.g. CompositeLit=0.2, separated by commas or
+// newlines. Text following # is a comment. Kinds that are not listed
+// weigh 1.
+func ParseWeights(r io.Reader) (syntax.Weights, error) {
+	weights := make(map[int]float64)
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		for _, pair := range strings.Split(text, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			eq := strings.IndexByte(pair, '=')
+			if eq < 0 {
+				return nil, fmt.Errorf("line %d: expected Kind=weight, got %q", line, pair)
+			}
+			name := strings.TrimSpace(pair[:eq])
+			kind, ok := KindByName(name)
+			if !ok {
+				return nil, fmt.Errorf("line %d: unknown node kind %q", line, name)
+			}
+			w, err := strconv.ParseFloat(strings.TrimSpace(pair[eq+1:]), 64)
+			if err != nil || w < 0 {
+				return nil, fmt.Errorf("line %d: invalid weight of %s", line, name)
+			}
+			weights[kind] = w
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return func(typ int) float64 {
+		if w, ok := weights[Kind(typ)]; ok {
+			return w
+		}
+		return 1
+	}, nil
+}
+
+// MaxWeight returns the maximum weight of a node kind, but at least 1.
+func MaxWeight(w syntax.Weights) float64 {
+	max := 1.0
+	for kind := range kindNames {
+		if kw := w(kind); kw > max {
+			max = kw
+		}
+	}
+	return max
+}
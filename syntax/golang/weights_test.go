@@ -0,0 +1,37 @@
+package golang
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWeights(t *testing.T) {
+	w, err := ParseWeights(strings.NewReader("CompositeLit=0.2, KeyValueExpr=0.1 # literals\n\nIfStmt = 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		typ    int
+		expect float64
+	}{
+		{CompositeLit, 0.2},
+		{KeyValueExpr, 0.1},
+		{IfStmt, 2},
+		{Ident, 1},
+		{BinaryExpr | 12<<kindBits, 1},
+	}
+	for _, tc := range testCases {
+		if actual := w(tc.typ); actual != tc.expect {
+			t.Errorf("weight of %s: got %v, want %v", kindNames[Kind(tc.typ)], actual, tc.expect)
+		}
+	}
+	if max := MaxWeight(w); max != 2 {
+		t.Errorf("got max weight %v, want 2", max)
+	}
+
+	for _, in := range []string{"Foo=1", "IfStmt", "IfStmt=x", "IfStmt=-1"} {
+		if _, err := ParseWeights(strings.NewReader(in)); err == nil {
+			t.Errorf("for '%s', expected error", in)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package syntax
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mibk/dupl/suffixtree"
+)
+
+// part is an exactly matching pair of sequences starting at a and b.
+type part struct {
+	a, b, len int
+}
+
+// FindNearMisses chains the exactly matching parts of the matches into
+// near-miss clones. Neighbouring parts may be separated by at most maxGap
+// tokens and, if maxGapStmts is positive, by at most maxGapStmts complete
+// syntax units (statements) in each of the fragments. Only clones whose
+// matching tokens are of the weighted size at least threshold are returned.
+func FindNearMisses(data []*Node, ms []suffixtree.Match, threshold int, w Weights, maxGap, maxGapStmts int) []Match {
+	parts := splitToParts(ms)
+	var matches []Match
+	used := make([]bool, len(parts))
+	for i := range parts {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		chain := []part{parts[i]}
+		for {
+			last := chain[len(chain)-1]
+			j, next := nextPart(data, parts, used, last, maxGap, maxGapStmts)
+			if j < 0 {
+				break
+			}
+			used[j] = true
+			chain = append(chain, next)
+		}
+		if len(chain) < 2 {
+			continue
+		}
+		if m, ok := nearMiss(data, chain, threshold, w); ok {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// splitToParts splits the matches to pairs of matching sequences. Each
+// position of a match is paired only with the closest following position
+// not overlapping it, so that the number of pairs is linear in the number
+// of positions. Pairs lying on the same diagonal that overlap are merged
+// together.
+func splitToParts(ms []suffixtree.Match) []part {
+	var parts []part
+	for _, m := range ms {
+		ps := make([]int, len(m.Ps))
+		for i, p := range m.Ps {
+			ps[i] = int(p)
+		}
+		sort.Ints(ps)
+		j := 0
+		for i, a := range ps {
+			if j <= i {
+				j = i + 1
+			}
+			for j < len(ps) && a+int(m.Len) > ps[j] {
+				j++
+			}
+			if j < len(ps) {
+				parts = append(parts, part{a, ps[j], int(m.Len)})
+			}
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		di, dj := parts[i].b-parts[i].a, parts[j].b-parts[j].a
+		if di != dj {
+			return di < dj
+		}
+		return parts[i].a < parts[j].a
+	})
+
+	var merged []part
+	for _, p := range parts {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if last.b-last.a == p.b-p.a && p.a <= last.a+last.len {
+				if end := p.a + p.len; end > last.a+last.len {
+					last.len = end - last.a
+				}
+				continue
+			}
+		}
+		merged = append(merged, p)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].a != merged[j].a {
+			return merged[i].a < merged[j].a
+		}
+		return merged[i].b < merged[j].b
+	})
+	return merged
+}
+
+// nextPart finds the closest part not used in other chains that can follow
+// the part last in a chain. The found part is trimmed so that it does not
+// overlap the part last.
+func nextPart(data []*Node, parts []part, used []bool, last part, maxGap, maxGapStmts int) (int, part) {
+	endA, endB := last.a+last.len, last.b+last.len
+	best, bestGap := -1, 0
+	var bestPart part
+	i := sort.Search(len(parts), func(i int) bool { return parts[i].a > last.a })
+	for ; i < len(parts) && parts[i].a <= endA+maxGap; i++ {
+		p := parts[i]
+		if used[i] || p.b <= last.b || p.a+p.len <= endA || p.b+p.len <= endB {
+			continue
+		}
+		d := maxInt(0, endA-p.a, endB-p.b)
+		p.a, p.b, p.len = p.a+d, p.b+d, p.len-d
+		gapA, gapB := p.a-endA, p.b-endB
+		if gapA > maxGap || gapB > maxGap || gapA+gapB == 0 {
+			continue
+		}
+		if maxGapStmts > 0 && (countUnits(data[endA:p.a]) > maxGapStmts ||
+			countUnits(data[endB:p.b]) > maxGapStmts) {
+			continue
+		}
+		if best < 0 || gapA+gapB < bestGap {
+			best, bestGap, bestPart = i, gapA+gapB, p
+		}
+	}
+	return best, bestPart
+}
+
+// countUnits counts complete syntax units in the sequence. Nodes that are
+// not complete within the sequence are not counted.
+func countUnits(seq []*Node) int {
+	var cnt int
+	for i := 0; i < len(seq); {
+		if n := seq[i]; n.Owns < len(seq)-i {
+			cnt++
+			i += n.Owns + 1
+			continue
+		}
+		i++
+	}
+	return cnt
+}
+
+func nearMiss(data []*Node, chain []part, threshold int, w Weights) (Match, bool) {
+	var matching int
+	var size float64
+	for _, p := range chain {
+		matching += p.len
+		size += w.size(data[p.a : p.a+p.len])
+	}
+	if size < float64(threshold) {
+		return Match{}, false
+	}
+	first, last := chain[0], chain[len(chain)-1]
+	seqA := data[first.a : last.a+last.len]
+	seqB := data[first.b : last.b+last.len]
+	if first.a+len(seqA) > first.b {
+		return Match{}, false
+	}
+
+	match := Match{
+		Hash:       fmt.Sprintf("near-miss %d %d", first.a, first.b),
+		Size:       w.size(seqA),
+		Similarity: float64(2*matching) / float64(len(seqA)+len(seqB)),
+	}
+	for _, seq := range [][]*Node{seqA, seqB} {
+		indexes := getUnitsIndexes(seq, 0, nil)
+		if len(indexes) == 0 || spansMultipleFiles(indexes, seq) {
+			return Match{}, false
+		}
+		frag := make([]*Node, len(indexes))
+		for i, index := range indexes {
+			frag[i] = seq[index]
+		}
+		match.Frags = append(match.Frags, frag)
+	}
+	return match, true
+}
+
+func maxInt(x int, ys ...int) int {
+	for _, y := range ys {
+		if y > x {
+			x = y
+		}
+	}
+	return x
+}
@@ -0,0 +1,91 @@
+package syntax
+
+import (
+	"testing"
+
+	"github.com/mibk/dupl/suffixtree"
+)
+
+func TestFindNearMisses(t *testing.T) {
+	testCases := []struct {
+		seq         string
+		ms          []suffixtree.Match
+		maxGap      int
+		maxGapStmts int
+		expected    int
+	}{
+		{"a0 b0 c0 d0 x0 e0 f0 g0 a0 b0 c0 d0 e0 f0 g0", []suffixtree.Match{{Ps: []suffixtree.Pos{0, 8}, Len: 4}, {Ps: []suffixtree.Pos{5, 12}, Len: 3}}, 1, 0, 1},
+		{"a0 b0 c0 d0 x0 e0 f0 g0 a0 b0 c0 d0 e0 f0 g0", []suffixtree.Match{{Ps: []suffixtree.Pos{0, 8}, Len: 4}, {Ps: []suffixtree.Pos{5, 12}, Len: 3}}, 0, 0, 0},
+		{"a0 b0 c0 d0 x1 y0 e0 f0 a0 b0 c0 d0 e0 f0", []suffixtree.Match{{Ps: []suffixtree.Pos{0, 8}, Len: 4}, {Ps: []suffixtree.Pos{6, 12}, Len: 2}}, 2, 1, 1},
+		{"a0 b0 c0 d0 x0 y0 e0 f0 a0 b0 c0 d0 e0 f0", []suffixtree.Match{{Ps: []suffixtree.Pos{0, 8}, Len: 4}, {Ps: []suffixtree.Pos{6, 12}, Len: 2}}, 2, 1, 0},
+		// overlapping parts are trimmed
+		{"a0 b0 c0 c0 d0 e0 a0 b0 c0 d0 e0", []suffixtree.Match{{Ps: []suffixtree.Pos{0, 6}, Len: 3}, {Ps: []suffixtree.Pos{3, 8}, Len: 3}}, 1, 0, 1},
+		// a part is chained into a single clone only
+		{"a0 b0 c0 x0 d0 e0 f0 y0 z0 w0 a0 a0 b0 c0 d0 e0 f0 v0", []suffixtree.Match{{Ps: []suffixtree.Pos{0, 10}, Len: 3}, {Ps: []suffixtree.Pos{0, 11}, Len: 3}, {Ps: []suffixtree.Pos{4, 14}, Len: 3}}, 1, 0, 1},
+	}
+
+	for _, tc := range testCases {
+		nodes := str2nodes(tc.seq)
+		matches := FindNearMisses(nodes, tc.ms, 5, nil, tc.maxGap, tc.maxGapStmts)
+		if len(matches) != tc.expected {
+			t.Errorf("for seq '%s', got %d near-miss clones, want %d", tc.seq, len(matches), tc.expected)
+		}
+		for _, m := range matches {
+			if len(m.Frags) != 2 || m.Similarity <= 0 || m.Similarity >= 1 {
+				t.Errorf("for seq '%s', got invalid near-miss clone %v", tc.seq, m)
+			}
+		}
+	}
+}
+
+func TestCountUnits(t *testing.T) {
+	testCases := []struct {
+		seq      string
+		expected int
+	}{
+		{"a0 b0", 2},
+		{"a2 b0 c0 d0", 2},
+		{"a5 b0 c0", 2},
+		{"a3 b1 c0 d0", 1},
+	}
+
+	for _, tc := range testCases {
+		if cnt := countUnits(str2nodes(tc.seq)); cnt != tc.expected {
+			t.Errorf("for seq '%s', got %d, want %d", tc.seq, cnt, tc.expected)
+		}
+	}
+}
+
+func TestSplitToParts(t *testing.T) {
+	testCases := []struct {
+		m     suffixtree.Match
+		parts []part
+	}{
+		{suffixtree.Match{Ps: []suffixtree.Pos{10, 0}, Len: 3}, []part{{0, 10, 3}}},
+		// each position is paired with the closest one not overlapping it
+		{suffixtree.Match{Ps: []suffixtree.Pos{4, 0, 10, 2}, Len: 3}, []part{{0, 4, 3}, {2, 10, 3}, {4, 10, 3}}},
+		{suffixtree.Match{Ps: []suffixtree.Pos{0, 1}, Len: 3}, nil},
+	}
+	for _, tc := range testCases {
+		parts := splitToParts([]suffixtree.Match{tc.m})
+		if len(parts) != len(tc.parts) {
+			t.Errorf("%v: got %v, want %v", tc.m.Ps, parts, tc.parts)
+			continue
+		}
+		for i := range parts {
+			if parts[i] != tc.parts[i] {
+				t.Errorf("%v: got %v, want %v", tc.m.Ps, parts, tc.parts)
+				break
+			}
+		}
+	}
+
+	// the number of parts is linear in the number of positions
+	m := suffixtree.Match{Len: 1}
+	for i := 0; i < 1000; i++ {
+		m.Ps = append(m.Ps, suffixtree.Pos(2*i))
+	}
+	if parts := splitToParts([]suffixtree.Match{m}); len(parts) >= len(m.Ps) {
+		t.Errorf("got %d parts of %d positions", len(parts), len(m.Ps))
+	}
+}
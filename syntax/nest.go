@@ -0,0 +1,84 @@
+package syntax
+
+import "sort"
+
+// Nest nests the matches whose fragments are all contained in fragments
+// of a larger match with at least as many fragments. Each match is nested
+// in the smallest such match. Nest returns the matches that are not nested
+// in any other; their order is preserved.
+func Nest(ms []Match) []Match {
+	order := make([]int, len(ms))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return extent(ms[order[i]]) > extent(ms[order[j]])
+	})
+
+	parent := make([]int, len(ms))
+	depth := make([]int, len(ms))
+	for k, i := range order {
+		parent[i] = -1
+		for l := k - 1; l >= 0; l-- {
+			if j := order[l]; contains(ms[j], ms[i]) {
+				parent[i], depth[i] = j, depth[j]+1
+				break
+			}
+		}
+	}
+
+	// attach the innermost matches first
+	sort.SliceStable(order, func(i, j int) bool {
+		if depth[order[i]] != depth[order[j]] {
+			return depth[order[i]] > depth[order[j]]
+		}
+		return order[i] < order[j]
+	})
+	nested := make([]Match, len(ms))
+	copy(nested, ms)
+	for _, i := range order {
+		if p := parent[i]; p >= 0 {
+			nested[p].Nested = append(nested[p].Nested, nested[i])
+		}
+	}
+	var top []Match
+	for i, m := range nested {
+		if parent[i] < 0 {
+			top = append(top, m)
+		}
+	}
+	return top
+}
+
+// extent returns the total length of the fragments in bytes.
+func extent(m Match) int {
+	var ext int
+	for _, frag := range m.Frags {
+		pos, end := Span(frag)
+		ext += end - pos
+	}
+	return ext
+}
+
+// contains reports whether m2 is contained in m.
+func contains(m, m2 Match) bool {
+	if len(m.Frags) < len(m2.Frags) {
+		return false
+	}
+Loop:
+	for _, frag2 := range m2.Frags {
+		for _, frag := range m.Frags {
+			if fragContains(frag, frag2) {
+				continue Loop
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func fragContains(frag, frag2 []*Node) bool {
+	pos, end := Span(frag)
+	pos2, end2 := Span(frag2)
+	return frag[0].Filename == frag2[0].Filename && pos <= pos2 && end2 <= end
+}
@@ -0,0 +1,37 @@
+package syntax
+
+import "testing"
+
+func TestNest(t *testing.T) {
+	frag := func(file string, pos, end int) []*Node {
+		return []*Node{{Filename: file, Pos: pos, End: end}}
+	}
+	fun := Match{Hash: "func", Frags: [][]*Node{frag("a", 0, 100), frag("b", 0, 100)}}
+	body := Match{Hash: "body", Frags: [][]*Node{frag("a", 10, 90), frag("b", 10, 90)}}
+	stmt := Match{Hash: "stmt", Frags: [][]*Node{frag("a", 20, 30), frag("b", 20, 30)}}
+	more := Match{Hash: "more", Frags: [][]*Node{frag("a", 20, 30), frag("b", 20, 30), frag("c", 0, 10)}}
+	other := Match{Hash: "other", Frags: [][]*Node{frag("a", 200, 300), frag("c", 100, 200)}}
+
+	top := Nest([]Match{stmt, other, body, more, fun})
+	if len(top) != 3 || top[0].Hash != "other" || top[1].Hash != "more" || top[2].Hash != "func" {
+		t.Fatalf("got top-level matches %v", hashes(top))
+	}
+	if nested := top[2].Nested; len(nested) != 1 || nested[0].Hash != "body" {
+		t.Fatalf("got %v nested in func, want [body]", hashes(nested))
+	}
+	if nested := top[2].Nested[0].Nested; len(nested) != 0 {
+		t.Fatalf("got %v nested in body, want none", hashes(nested))
+	}
+	// stmt is nested in the smallest match containing it
+	if nested := top[1].Nested; len(nested) != 1 || nested[0].Hash != "stmt" {
+		t.Fatalf("got %v nested in more, want [stmt]", hashes(nested))
+	}
+}
+
+func hashes(ms []Match) []string {
+	var hs []string
+	for _, m := range ms {
+		hs = append(hs, m.Hash)
+	}
+	return hs
+}
@@ -0,0 +1,164 @@
+package syntax
+
+import "sort"
+
+// unit is a complete syntax unit in the serialized data.
+type unit struct {
+	index int
+	seq   []*Node
+	hist  map[int]int
+}
+
+// FindSimilarUnits compares the syntax units for which isUnit returns true
+// and of the weighted size at least threshold. It returns groups of identical units
+// and pairs of groups whose units are at least minSimilarity similar.
+// The matches are ranked by their similarity and size.
+func FindSimilarUnits(data []*Node, isUnit func(*Node) bool, threshold int, w Weights, minSimilarity float64) []Match {
+	var hashes []string
+	groups := make(map[string][]unit)
+	for i, n := range data {
+		if !isUnit(n) {
+			continue
+		}
+		u := unit{index: i, seq: data[i : i+n.Owns+1]}
+		if w.size(u.seq) < float64(threshold) {
+			continue
+		}
+		hash := hashSeq(u.seq)
+		if _, ok := groups[hash]; !ok {
+			hashes = append(hashes, hash)
+		}
+		groups[hash] = append(groups[hash], u)
+	}
+
+	var identical []unit
+	for _, g := range groups {
+		if len(g) > 1 {
+			identical = append(identical, g...)
+		}
+	}
+	var matches []Match
+	for _, hash := range hashes {
+		g := groups[hash]
+		if len(g) > 1 && !isNested(g, identical) {
+			matches = append(matches, Match{Hash: hash, Frags: unitFrags(g), Size: w.size(g[0].seq), Similarity: 1})
+		}
+	}
+
+	if minSimilarity < 1 {
+		sort.Slice(hashes, func(i, j int) bool {
+			return len(groups[hashes[i]][0].seq) < len(groups[hashes[j]][0].seq)
+		})
+		for _, hash := range hashes {
+			groups[hash][0].hist = histogram(groups[hash][0].seq)
+		}
+		for i, hash := range hashes {
+			u := groups[hash][0]
+			for _, hash2 := range hashes[i+1:] {
+				u2 := groups[hash2][0]
+				// the units are sorted by size, so are the upper bounds
+				if similarity(len(u.seq), len(u2.seq), len(u.seq)) < minSimilarity {
+					break
+				}
+				if u2.contains(u) || similarity(len(u.seq), len(u2.seq), commonCount(u.hist, u2.hist)) < minSimilarity {
+					continue
+				}
+				sim := similarity(len(u.seq), len(u2.seq), lcs(u.seq, u2.seq))
+				if sim < minSimilarity {
+					continue
+				}
+				matches = append(matches, Match{
+					Hash:       hash + hash2,
+					Frags:      append(unitFrags(groups[hash]), unitFrags(groups[hash2])...),
+					Size:       w.size(u2.seq),
+					Similarity: sim,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		mi, mj := matches[i], matches[j]
+		if mi.Similarity != mj.Similarity {
+			return mi.Similarity > mj.Similarity
+		}
+		return mi.Size > mj.Size
+	})
+	return matches
+}
+
+// contains reports whether u2 is nested in u.
+func (u unit) contains(u2 unit) bool {
+	return u.index < u2.index && u2.index+len(u2.seq) <= u.index+len(u.seq)
+}
+
+func unitFrags(g []unit) [][]*Node {
+	frags := make([][]*Node, len(g))
+	for i, u := range g {
+		frags[i] = []*Node{u.seq[0]}
+	}
+	return frags
+}
+
+// isNested reports whether each unit of the group g is nested in one
+// of the outer units.
+func isNested(g []unit, outer []unit) bool {
+Loop:
+	for _, u := range g {
+		for _, o := range outer {
+			if o.contains(u) {
+				continue Loop
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// similarity returns the similarity of sequences of lengths n and m
+// with the given number of common tokens.
+func similarity(n, m, common int) float64 {
+	return float64(2*common) / float64(n+m)
+}
+
+func histogram(seq []*Node) map[int]int {
+	hist := make(map[int]int)
+	for _, n := range seq {
+		hist[n.Type]++
+	}
+	return hist
+}
+
+// commonCount returns the number of tokens two sequences with the histograms
+// h1 and h2 have in common regardless of their order.
+func commonCount(h1, h2 map[int]int) int {
+	var cnt int
+	for typ, c1 := range h1 {
+		if c2 := h2[typ]; c2 < c1 {
+			cnt += c2
+		} else {
+			cnt += c1
+		}
+	}
+	return cnt
+}
+
+// lcs returns the length of the longest common subsequence of node types.
+func lcs(s1, s2 []*Node) int {
+	prev := make([]int, len(s2)+1)
+	cur := make([]int, len(s2)+1)
+	for _, n1 := range s1 {
+		for j, n2 := range s2 {
+			switch {
+			case n1.Type == n2.Type:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] > cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(s2)]
+}
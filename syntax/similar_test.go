@@ -0,0 +1,50 @@
+package syntax
+
+import "testing"
+
+func TestLCS(t *testing.T) {
+	testCases := []struct {
+		s1, s2   string
+		expected int
+	}{
+		{"a0 b0 c0", "a0 b0 c0", 3},
+		{"a0 b0 c0", "a0 c0", 2},
+		{"a0 b0 c0 d0", "b0 a0 d0 c0", 2},
+		{"a0", "b0", 0},
+	}
+
+	for _, tc := range testCases {
+		if l := lcs(str2nodes(tc.s1), str2nodes(tc.s2)); l != tc.expected {
+			t.Errorf("lcs of '%s' and '%s': got %d, want %d", tc.s1, tc.s2, l, tc.expected)
+		}
+	}
+}
+
+func TestFindSimilarUnits(t *testing.T) {
+	isUnit := func(n *Node) bool { return n.Type == 'f' }
+	testCases := []struct {
+		seq           string
+		minSimilarity float64
+		expected      []float64
+	}{
+		{"f2 a0 b0 f2 a0 b0 f3 a0 b0 c0", 1, []float64{1}},
+		{"f2 a0 b0 f2 a0 b0 f3 a0 b0 c0", 0.8, []float64{1, 6.0 / 7}},
+		{"f2 a0 b0 f3 a0 c0 b0", 0.8, []float64{6.0 / 7}},
+		{"f2 a0 b0 f3 c0 d0 e0", 0.5, nil},
+		// nested units are not compared with their parents
+		{"f3 a0 f1 a0 f3 a0 f1 a0", 0.5, []float64{1}},
+	}
+
+	for _, tc := range testCases {
+		matches := FindSimilarUnits(str2nodes(tc.seq), isUnit, 1, nil, tc.minSimilarity)
+		if len(matches) != len(tc.expected) {
+			t.Errorf("for seq '%s', got %d matches, want %d", tc.seq, len(matches), len(tc.expected))
+			continue
+		}
+		for i, m := range matches {
+			if m.Similarity != tc.expected[i] {
+				t.Errorf("for seq '%s', got similarity %v, want %v", tc.seq, m.Similarity, tc.expected[i])
+			}
+		}
+	}
+}
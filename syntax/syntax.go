@@ -2,6 +2,8 @@ package syntax
 
 import (
 	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
 
 	"github.com/mibk/dupl/suffixtree"
 )
@@ -12,23 +14,116 @@ type Node struct {
 	Pos, End int
 	Children []*Node
 	Owns     int
+
+	// Corpus is the index of the compared corpus the node comes from.
+	Corpus int
 }
 
 func NewNode() *Node {
 	return &Node{}
 }
 
+// AddChildren adds the children to the node. Nil children are skipped.
 func (n *Node) AddChildren(children ...*Node) {
-	n.Children = append(n.Children, children...)
+	for _, child := range children {
+		if child != nil {
+			n.Children = append(n.Children, child)
+		}
+	}
 }
 
 func (n *Node) Val() int {
 	return n.Type
 }
 
+// Span returns the offsets of the start and the end of the fragment.
+// The nodes are not ordered by their offsets, e.g. the right-hand sides
+// of assignments precede the left-hand sides.
+func Span(frag []*Node) (pos, end int) {
+	pos, end = frag[0].Pos, frag[0].End
+	for _, n := range frag[1:] {
+		if n.Pos < pos {
+			pos = n.Pos
+		}
+		if n.End > end {
+			end = n.End
+		}
+	}
+	return pos, end
+}
+
 type Match struct {
 	Hash  string
 	Frags [][]*Node
+
+	// Size is the weighted size of the first fragment.
+	Size float64
+
+	// Similarity is the ratio of matching tokens in the fragments.
+	// It is 1 for exact clones.
+	Similarity float64
+
+	// TextSimilarity is the mean textual similarity of the fragments and
+	// PairSimilarity[i][j] is the textual similarity of the fragments
+	// i and j. They are not set by the syntax package.
+	TextSimilarity float64
+	PairSimilarity [][]float64
+
+	// Suggestions are the suggested refactorings of the clones. They are
+	// not set by the syntax package.
+	Suggestions []string
+
+	// Warnings describe likely bugs in the clones, each prefixed by its
+	// location, e.g. "a.go:12:5: ...". They are not set by the syntax
+	// package.
+	Warnings []string
+
+	// Period is the number of syntax units in the repeated pattern if
+	// the match is a repetition, whose fragments are the consecutive
+	// repetitions of the pattern. It is 0 for clones.
+	Period int
+
+	// Literal is the source of the literal if the fragments are its
+	// occurrences. It is not set by the syntax package.
+	Literal string
+
+	// Decl is the kind and the name of the first type if the fragments
+	// are declarations of similar types, e.g. "struct User". It is not
+	// set by the syntax package.
+	Decl string
+
+	// Suspect describes the fragments if they are identical parts of
+	// a single construct, e.g. "identical case bodies". It is not set
+	// by the syntax package.
+	Suspect string
+
+	// Method is the name and the signature of the methods if the fragments
+	// are implementations of the same method on different types, e.g.
+	// "Close() error". It is not set by the syntax package.
+	Method string
+
+	// Enclosing are the labels of the declarations enclosing the fragments
+	// in the order of Frags, e.g. "(*Server).handleLogin". It is not set
+	// by the syntax package.
+	Enclosing []string
+
+	// Nested are the matches contained in this match, see Nest.
+	Nested []Match
+}
+
+// Weights returns the weight of a node type used to compute the size
+// of clones. If it is nil, each node weighs 1.
+type Weights func(typ int) float64
+
+func (w Weights) size(seq []*Node) float64 {
+	if w == nil {
+		return float64(len(seq))
+	}
+	var size float64
+	for _, n := range seq {
+		size += w(n.Type)
+	}
+	return size
 }
 
 func Serialize(n *Node) []*Node {
@@ -48,13 +143,13 @@ func serial(n *Node, stream *[]*Node) int {
 }
 
 // FindSyntaxUnits finds all complete syntax units in the match group and returns them
-// with the corresponding hash.
-func FindSyntaxUnits(data []*Node, m suffixtree.Match, threshold int) Match {
+// with the corresponding hash. The units must be of the weighted size at least threshold.
+func FindSyntaxUnits(data []*Node, m suffixtree.Match, threshold int, w Weights) Match {
 	if len(m.Ps) == 0 {
 		return Match{}
 	}
 	firstSeq := data[m.Ps[0] : m.Ps[0]+m.Len]
-	indexes := getUnitsIndexes(firstSeq, threshold)
+	indexes := getUnitsIndexes(firstSeq, threshold, w)
 
 	// TODO: is this really working?
 	indexCnt := len(indexes)
@@ -69,11 +164,14 @@ func FindSyntaxUnits(data []*Node, m suffixtree.Match, threshold int) Match {
 			}
 		}
 	}
-	if len(indexes) == 0 || isCyclic(indexes, firstSeq) || spansMultipleFiles(indexes, firstSeq) {
+	if len(indexes) == 0 || spansMultipleFiles(indexes, firstSeq) {
 		return Match{}
 	}
+	if period := cyclePeriod(indexes, firstSeq); period > 0 {
+		return repetition(data, m, indexes, period, w)
+	}
 
-	match := Match{Frags: make([][]*Node, len(m.Ps))}
+	match := Match{Frags: make([][]*Node, len(m.Ps)), Similarity: 1}
 	for i, pos := range m.Ps {
 		match.Frags[i] = make([]*Node, len(indexes))
 		for j, index := range indexes {
@@ -83,10 +181,11 @@ func FindSyntaxUnits(data []*Node, m suffixtree.Match, threshold int) Match {
 
 	lastIndex := indexes[len(indexes)-1]
 	match.Hash = hashSeq(firstSeq[indexes[0] : lastIndex+firstSeq[lastIndex].Owns])
+	match.Size = w.size(firstSeq[indexes[0] : lastIndex+firstSeq[lastIndex].Owns+1])
 	return match
 }
 
-func getUnitsIndexes(nodeSeq []*Node, threshold int) []int {
+func getUnitsIndexes(nodeSeq []*Node, threshold int, w Weights) []int {
 	var indexes []int
 	var split bool
 	for i := 0; i < len(nodeSeq); {
@@ -97,7 +196,7 @@ func getUnitsIndexes(nodeSeq []*Node, threshold int) []int {
 			i++
 			split = true
 			continue
-		case n.Owns+1 < threshold:
+		case w.size(nodeSeq[i:i+n.Owns+1]) < float64(threshold):
 			split = true
 		default:
 			if split {
@@ -111,25 +210,26 @@ func getUnitsIndexes(nodeSeq []*Node, threshold int) []int {
 	return indexes
 }
 
-// isCyclic finds out whether there is a repetitive pattern in the found clone. If positive,
-// it returns true to point out that the clone would be redundant.
-func isCyclic(indexes []int, nodes []*Node) bool {
+// cyclePeriod finds out whether there is a repetitive pattern in the found clone. If positive,
+// it returns the number of syntax units in the pattern, otherwise 0.
+func cyclePeriod(indexes []int, nodes []*Node) int {
 	cnt := len(indexes)
 	if cnt <= 1 {
-		return false
+		return 0
 	}
 
-	alts := make(map[int]bool)
+	var alts []int
 	for i := 1; i <= cnt/2; i++ {
 		if cnt%i == 0 {
-			alts[i] = true
+			alts = append(alts, i)
 		}
 	}
 
-	for i := 0; i < indexes[cnt/2]; i++ {
+	for i := 0; i < indexes[cnt/2]-indexes[0]; i++ {
 		nstart := nodes[i+indexes[0]]
+		var left []int
 	AltLoop:
-		for alt := range alts {
+		for _, alt := range alts {
 			for j := alt; j < cnt; j += alt {
 				index := i + indexes[j]
 				if index < len(nodes) {
@@ -137,14 +237,89 @@ func isCyclic(indexes []int, nodes []*Node) bool {
 					if nstart.Owns == nalt.Owns && nstart.Type == nalt.Type {
 						continue
 					}
-				} else if i >= indexes[alt] {
-					return true
+				} else if i >= indexes[alt]-indexes[0] {
+					return alt
 				}
-				delete(alts, alt)
 				continue AltLoop
 			}
+			left = append(left, alt)
 		}
+		alts = left
 		if len(alts) == 0 {
+			return 0
+		}
+	}
+	return alts[0]
+}
+
+// repetition returns the run of consecutive repetitions of the pattern
+// of period syntax units found in the match m. The fragments of the
+// returned match are the repetitions. The run is hashed by its true start,
+// which may precede the match, so that the overlapping matches of the run
+// are grouped together.
+func repetition(data []*Node, m suffixtree.Match, indexes []int, period int, w Weights) Match {
+	start, end := int(m.Ps[0]), int(m.Ps[0])
+	for _, pos := range m.Ps[1:] {
+		if p := int(pos); p < start {
+			start = p
+		} else if p > end {
+			end = p
+		}
+	}
+	end += int(m.Len)
+
+	pattern := make([]int, period)
+	for k := range pattern {
+		pattern[k] = int(m.Ps[0]) + indexes[k]
+	}
+	lastIndex := pattern[period-1]
+	last := data[lastIndex]
+	length := lastIndex + last.Owns + 1 - pattern[0]
+
+	// repeats reports whether a repetition of the pattern starts at i
+	repeats := func(i int) bool {
+		for _, j := range pattern {
+			k := i + j - pattern[0]
+			if !sameUnits(data, j, k) || data[k].Filename != data[j].Filename {
+				return false
+			}
+		}
+		return true
+	}
+	first := start + indexes[0]
+	for first >= length && repeats(first-length) {
+		first -= length
+	}
+	var frags [][]*Node
+	for i := first; i+length <= end && repeats(i); i += length {
+		frag := make([]*Node, period)
+		for k, j := range pattern {
+			frag[k] = data[i+j-pattern[0]]
+		}
+		frags = append(frags, frag)
+	}
+	if len(frags) < 2 {
+		return Match{}
+	}
+
+	return Match{
+		Hash:       fmt.Sprintf("repetition %s %d %d", data[first].Filename, data[first].Pos, period),
+		Frags:      frags,
+		Size:       w.size(data[pattern[0] : lastIndex+last.Owns+1]),
+		Similarity: 1,
+		Period:     period,
+	}
+}
+
+// sameUnits reports whether the syntax units starting at i and j
+// in data are of the same structure.
+func sameUnits(data []*Node, i, j int) bool {
+	n := data[i].Owns + 1
+	if j+n > len(data) {
+		return false
+	}
+	for k := 0; k < n; k++ {
+		if data[i+k].Type != data[j+k].Type || data[i+k].Owns != data[j+k].Owns {
 			return false
 		}
 	}
@@ -166,9 +341,9 @@ func spansMultipleFiles(indexes []int, nodes []*Node) bool {
 
 func hashSeq(nodes []*Node) string {
 	h := sha1.New()
-	bytes := make([]byte, len(nodes))
-	for i, node := range nodes {
-		bytes[i] = byte(node.Type)
+	bytes := make([]byte, 0, len(nodes))
+	for _, node := range nodes {
+		bytes = binary.AppendUvarint(bytes, uint64(node.Type))
 	}
 	h.Write(bytes)
 	return string(h.Sum(nil))
@@ -1,6 +1,10 @@
 package syntax
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/mibk/dupl/suffixtree"
+)
 
 func TestSerialization(t *testing.T) {
 	n := genNodes(7)
@@ -58,7 +62,7 @@ func TestGetUnitsIndexes(t *testing.T) {
 Loop:
 	for _, tc := range testCases {
 		nodes := str2nodes(tc.seq)
-		indexes := getUnitsIndexes(nodes, tc.threshold)
+		indexes := getUnitsIndexes(nodes, tc.threshold, nil)
 		for i := range tc.expected {
 			if i > len(indexes)-1 || tc.expected[i] != indexes[i] {
 				t.Errorf("for seq '%s', got %v, want %v", tc.seq, indexes, tc.expected)
@@ -88,12 +92,70 @@ func TestCyclicDupl(t *testing.T) {
 
 	for _, tc := range testCases {
 		nodes := str2nodes(tc.seq)
-		if tc.expected != isCyclic(tc.indexes, nodes) {
+		if tc.expected != (cyclePeriod(tc.indexes, nodes) > 0) {
 			t.Errorf("for seq '%s', indexes %v, got %t, want %t", tc.seq, tc.indexes, !tc.expected, tc.expected)
 		}
 	}
 }
 
+func TestCyclePeriod(t *testing.T) {
+	testCases := []struct {
+		seq      string
+		indexes  []int
+		expected int
+	}{
+		{"a1 b0 a1 b0", []int{0, 2}, 1},
+		{"a0 a0", []int{0, 1}, 1},
+		{"a1 b0 c1 b0 a1 b0 c1 b0", []int{0, 2, 4, 6}, 2},
+		{"a2 b0 b0 a2 b0 b0 a2 b0 b0 a2 b0 b0 a2 b0 b0", []int{0, 3, 6, 9, 12}, 1},
+		{"b0 a1 b0 a1 b0 a1", []int{1, 3}, 1},
+	}
+
+	for _, tc := range testCases {
+		nodes := str2nodes(tc.seq)
+		if actual := cyclePeriod(tc.indexes, nodes); actual != tc.expected {
+			t.Errorf("for seq '%s', indexes %v, got %d, want %d", tc.seq, tc.indexes, actual, tc.expected)
+		}
+	}
+}
+
+func TestRepetition(t *testing.T) {
+	data := str2nodes("x0 a1 b0 c0 a1 b0 c0 a1 b0 c0 a1 b0 y0")
+	// a maximal repeat of "a b c a b c" found at the positions 1 and 4
+	m := suffixtree.Match{Ps: []suffixtree.Pos{4, 1}, Len: 6}
+	match := FindSyntaxUnits(data, m, 1, nil)
+	if match.Period != 2 {
+		t.Fatalf("got period %d, want 2", match.Period)
+	}
+	if len(match.Frags) != 3 {
+		t.Fatalf("got %d repetitions, want 3", len(match.Frags))
+	}
+	for i, frag := range match.Frags {
+		if frag[0] != data[1+3*i] || frag[1] != data[3+3*i] {
+			t.Errorf("repetition %d does not start at %d", i, 1+3*i)
+		}
+	}
+
+	data = str2nodes("x0 a1 b0 a1 b0 a1 b0 a1 b0 y0")
+	for i, n := range data {
+		n.Pos = i
+	}
+	// the maximal repeats of the run of four repetitions of "a b"
+	ms := []suffixtree.Match{
+		{Ps: []suffixtree.Pos{1, 3}, Len: 6},
+		{Ps: []suffixtree.Pos{5, 3}, Len: 4},
+	}
+	for _, m := range ms {
+		match := FindSyntaxUnits(data, m, 1, nil)
+		if match.Hash != "repetition  1 1" {
+			t.Errorf("%v: got hash %q, want the hash of the run", m.Ps, match.Hash)
+		}
+		if len(match.Frags) != 4 || match.Frags[0][0] != data[1] {
+			t.Errorf("%v: got %d repetitions, want 4 starting at 1", m.Ps, len(match.Frags))
+		}
+	}
+}
+
 // str2nodes converts strint to a sequence of *Node by following principle:
 //   - node is represented by 2 characters
 //   - first character is node type